@@ -18,8 +18,8 @@ type asciiPackager struct {
 	slaveID byte
 }
 
-func (p *asciiPackager) Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, err error) {
-	data := make([]byte, 3+len(pdu.GetData()))
+func (p *asciiPackager) Encode(pdu ProtocolDataUnit) (adu ApplicationDataUnit, err error) {
+	data := make([]byte, 2+len(pdu.GetData()))
 	data[0] = p.slaveID
 	data[1] = pdu.GetFunctionCode()
 	copy(data[2:], pdu.GetData())
@@ -44,44 +44,34 @@ func (p *asciiPackager) Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, e
 }
 func (p *asciiPackager) Decode(results []byte) (adu ApplicationDataUnit, err error) {
 	length := len(results)
-	if length > asciiMaxSize {
-		err = fmt.Errorf("modbus: response data size '%v' exceeds the maximum limit of '%v'", length, asciiMaxSize)
+	if length > asciiMaxSize+len(asciiStart)+len(asciiEnd) {
+		err = framingError(ASCII, "response data size '%v' exceeds the maximum limit of '%v'", length, asciiMaxSize+len(asciiStart)+len(asciiEnd))
 		return
 	}
 	if length < asciiMinSize {
-		err = fmt.Errorf("modbus: response data size '%v' less than maximum limit of '%v'", length, asciiMinSize)
+		err = framingError(ASCII, "response data size '%v' less than minimum limit of '%v'", length, asciiMinSize)
 		return
 	}
-	slaveIDBs, err := hex.DecodeString(string(results[1:3]))
-	if err != nil {
+	if string(results[:len(asciiStart)]) != asciiStart || string(results[length-len(asciiEnd):]) != asciiEnd {
+		err = framingError(ASCII, "response frame '%q' is not delimited by '%q' and '%q'", results, asciiStart, asciiEnd)
 		return
 	}
-	slaveID := slaveIDBs[0]
-	functionCodeBs, err := hex.DecodeString(string(results[3:5]))
-	if err != nil {
+	data, e := hex.DecodeString(string(results[len(asciiStart) : length-len(asciiEnd)]))
+	if e != nil {
+		err = &FramingError{Mode: ASCII, Message: "response frame is not valid hex", Err: e}
 		return
 	}
-	functionCode := functionCodeBs[0]
-	pduLengthBs, err := hex.DecodeString(string(results[5:7]))
-	if err != nil {
+	if len(data) < 3 {
+		err = framingError(ASCII, "response data size '%v' less than minimum limit of '%v'", len(data), 3)
 		return
 	}
-	pduLength := pduLengthBs[0]
-	pduData := results[7 : length-4]
-	pduData, err = hex.DecodeString(string(pduData))
-	if err != nil {
-		return
-	}
-	pdu := protocolDataUnit{
-		functionCode: functionCode,
-		data:         pduData,
-		length:       int(pduLength),
-	}
-	checkSum := results[length-4 : length-2]
-	checkSum, err = hex.DecodeString(string(checkSum))
+	slaveID := data[0]
+	functionCode := data[1]
+	pdu, err := decodePDU(ASCII, functionCode, data[2:len(data)-1])
 	if err != nil {
 		return
 	}
+	checkSum := data[len(data)-1:]
 	adu = applicationDataUnit{
 		slaveID:      slaveID,
 		pdu:          pdu,
@@ -93,41 +83,78 @@ func (p *asciiPackager) Decode(results []byte) (adu ApplicationDataUnit, err err
 	}
 	return
 }
-func (p *asciiPackager) Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
-	if aduRequest.GetSlaveId() != aduResponse.GetSlaveId() {
-		err = fmt.Errorf("modbus: aduRequest  slaveId '%v' and aduResponse slaveId '%v' are inconsistent", aduRequest.GetSlaveId(), aduResponse.GetSlaveId())
+func (p *asciiPackager) DecodeRequest(results []byte) (adu ApplicationDataUnit, request *Request, err error) {
+	length := len(results)
+	if length > asciiMaxSize+len(asciiStart)+len(asciiEnd) {
+		err = framingError(ASCII, "request data size '%v' exceeds the maximum limit of '%v'", length, asciiMaxSize+len(asciiStart)+len(asciiEnd))
+		return
+	}
+	if length < asciiMinSize {
+		err = framingError(ASCII, "request data size '%v' less than minimum limit of '%v'", length, asciiMinSize)
+		return
+	}
+	if string(results[:len(asciiStart)]) != asciiStart || string(results[length-len(asciiEnd):]) != asciiEnd {
+		err = framingError(ASCII, "request frame '%q' is not delimited by '%q' and '%q'", results, asciiStart, asciiEnd)
+		return
+	}
+	data, e := hex.DecodeString(string(results[len(asciiStart) : length-len(asciiEnd)]))
+	if e != nil {
+		err = &FramingError{Mode: ASCII, Message: "request frame is not valid hex", Err: e}
+		return
+	}
+	if len(data) < 3 {
+		err = framingError(ASCII, "request data size '%v' less than minimum limit of '%v'", len(data), 3)
 		return
 	}
-	if aduRequest.GetFunctionCode() != aduResponse.GetFunctionCode() {
-		if aduResponse.GetFunctionCode() == aduRequest.GetFunctionCode()+0x80 {
-			err = fmt.Errorf("modbus: error   errorCode '%X'", aduResponse.GetFunctionCode())
-			data := aduResponse.GetPDU().GetData()
-			if len(data) == 0 {
-				return
-			}
-			text, exist := faults[data[0]]
-			if !exist {
-				return
-			}
-			err = fmt.Errorf("modbus: error errorCode: '%X' %s", aduResponse.GetFunctionCode(), text)
-			return
-		}
-		err = fmt.Errorf("modbus: aduRequest  functionCode '%v' and aduResponse functionCode '%v' are inconsistent", aduRequest.GetFunctionCode(), aduResponse.GetFunctionCode())
+	checkSum := data[len(data)-1]
+	if LRC(data[:len(data)-1]) != checkSum {
+		err = &ChecksumError{Mode: ASCII, Source: []byte{checkSum}, Reality: []byte{LRC(data[:len(data)-1])}}
 		return
 	}
+	slaveID := data[0]
+	pduData := data[2 : len(data)-1]
+	pdu := protocolDataUnit{
+		functionCode: data[1],
+		data:         pduData,
+		length:       len(pduData),
+	}
+	adu = applicationDataUnit{
+		slaveID:      slaveID,
+		pdu:          pdu,
+		checkSumByte: []byte{checkSum},
+		checkSum:     uint16(checkSum),
+		data:         results,
+		length:       length,
+		mode:         ASCII,
+	}
+	request, err = ParseRequest(pdu)
+	request.SlaveID = slaveID
+	return
+}
+func (p *asciiPackager) Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
 	data := aduResponse.GetData()
-	data, err = hex.DecodeString(string(data[1 : len(data)-4]))
+	data, err = hex.DecodeString(string(data[len(asciiStart) : len(data)-len(asciiEnd)-2]))
 	if err != nil {
+		err = &FramingError{Mode: ASCII, Message: "response frame is not valid hex", Err: err}
 		return
 	}
 	checksum := LRC(data)
 	if uint16(checksum) != aduResponse.GetCheckSum() {
-		err = fmt.Errorf("modbus: lrc validation failed source:'%v' reality:'%v' ", hex.EncodeToString(aduResponse.GetCheckSumByte()), hex.EncodeToString([]byte{checksum}))
+		err = &ChecksumError{Mode: ASCII, Source: aduResponse.GetCheckSumByte(), Reality: []byte{checksum}}
+		return
+	}
+	if aduRequest.GetSlaveId() != aduResponse.GetSlaveId() {
+		err = fmt.Errorf("modbus: aduRequest  slaveId '%v' and aduResponse slaveId '%v' are inconsistent", aduRequest.GetSlaveId(), aduResponse.GetSlaveId())
 		return
 	}
+	err = verifyException(aduRequest, aduResponse)
 	return
 }
 
+func (p *asciiPackager) ForUnit(slaveID byte) Packager {
+	return &asciiPackager{slaveID: slaveID}
+}
+
 func NewAsciiPackager(slaveID byte) (p Packager) {
 	p = &asciiPackager{slaveID: slaveID}
 	return
@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSerialBroadcast(t *testing.T) {
+	port := newTestPort()
+	st := &SerialPortTransporter{port: port, TurnaroundDelay: 50 * time.Millisecond}
+	st.BaudRate = 115200
+	c := NewModbusClient(NewRtuPackager(1), st)
+	request, err := c.BroadcastWriteSingleRegister(3, 0x1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.GetSlaveId() != 0 {
+		t.Fatalf("unexpected slave id %d", request.GetSlaveId())
+	}
+	if frame := <-port.written; frame[0] != 0 || frame[1] != FuncCodeWriteSingleRegister {
+		t.Fatalf("unexpected frame %X", frame)
+	}
+	start := time.Now()
+	if _, err = c.BroadcastWriteMultipleCoils(0, 3, []bool{true, false, true}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("turnaround delay not observed, elapsed %v", elapsed)
+	}
+	if frame := <-port.written; frame[0] != 0 || frame[1] != FuncCodeWriteMultipleCoils {
+		t.Fatalf("unexpected frame %X", frame)
+	}
+}
+
+func TestBroadcastTurnaroundContext(t *testing.T) {
+	port := newTestPort()
+	st := &SerialPortTransporter{port: port, TurnaroundDelay: time.Second}
+	c := NewModbusClient(NewRtuPackager(1), st)
+	if _, err := c.BroadcastWriteSingleCoil(1, true); err != nil {
+		t.Fatal(err)
+	}
+	<-port.written
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.WithContext(ctx).ReadCoils(0, 1); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestTcpBroadcast(t *testing.T) {
+	st := NewTcpTransporter(startSilentServer(t))
+	st.TurnaroundDelay = time.Millisecond
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	request, err := c.BroadcastWriteMultipleRegisters(0, 2, dataBlock(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := request.GetData(); data[6] != 0 || data[7] != FuncCodeWriteMultipleRegisters {
+		t.Fatalf("unexpected frame %X", data)
+	}
+	if _, err = c.BroadcastWriteSingleRegister(0, 1); err != nil {
+		t.Fatal(err)
+	}
+}
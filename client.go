@@ -1,6 +1,7 @@
 package modbus
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 )
@@ -63,13 +64,141 @@ type Client interface {
 	Send(request ApplicationDataUnit) (results ApplicationDataUnit, err error)
 }
 
-// 客户端 结构体
-type client struct {
+// ValueClient 读取并解析为位值或寄存器值,NewClient 返回的客户端实现此接口
+type ValueClient interface {
+	// ReadCoilValues 读线圈并解析为位值
+	//功能码:1
+	ReadCoilValues(address, quantity uint16) (value []bool, err error)
+	// ReadDiscreteInputValues 读离散量输入并解析为位值
+	//功能码:2
+	ReadDiscreteInputValues(address, quantity uint16) (value []bool, err error)
+	// ReadInputRegisterValues 读输入寄存器并解析为寄存器值
+	//功能码:4
+	ReadInputRegisterValues(address, quantity uint16) (value []uint16, err error)
+	// ReadHoldingRegisterValues 读保持寄存器并解析为寄存器值
+	//功能码:3
+	ReadHoldingRegisterValues(address, quantity uint16) (value []uint16, err error)
+}
+
+// BroadcastClient 广播写,从站地址为0,发送后不等待响应,传输器需实现 BroadcastTransporter
+type BroadcastClient interface {
+	// BroadcastWriteSingleCoil 广播写单个线圈
+	//功能码:5
+	BroadcastWriteSingleCoil(address uint16, value bool) (request ApplicationDataUnit, err error)
+	// BroadcastWriteMultipleCoils 广播写多个线圈
+	//功能码:15
+	BroadcastWriteMultipleCoils(address, quantity uint16, value []bool) (request ApplicationDataUnit, err error)
+	// BroadcastWriteSingleRegister 广播写单个寄存器
+	//功能码:6
+	BroadcastWriteSingleRegister(address, value uint16) (request ApplicationDataUnit, err error)
+	// BroadcastWriteMultipleRegisters 广播写多个寄存器
+	//功能码:16
+	BroadcastWriteMultipleRegisters(address, quantity uint16, value []byte) (request ApplicationDataUnit, err error)
+}
+
+// MaskWriteRegisterClient 屏蔽写寄存器
+type MaskWriteRegisterClient interface {
+	// MaskWriteRegister 屏蔽写寄存器,结果为 (当前值 AND andMask) OR (orMask AND (NOT andMask))
+	//地址:0~65535
+	//功能码:22
+	MaskWriteRegister(address, andMask, orMask uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error)
+}
+
+// ReadFIFOQueueClient 读FIFO队列
+type ReadFIFOQueueClient interface {
+	// ReadFIFOQueue 读FIFO队列,返回队列中的寄存器值,队列最多31个寄存器
+	//地址:0~65535
+	//功能码:24
+	ReadFIFOQueue(address uint16) (value []uint16, err error)
+}
+
+// DeviceIdentificationClient 读设备识别码
+type DeviceIdentificationClient interface {
+	// ReadDeviceIdentification 读设备识别码,返回对象ID与值,流式读取时自动读取后续对象
+	//读取类型:ReadDeviceIDBasic、ReadDeviceIDRegular、ReadDeviceIDExtended、ReadDeviceIDSpecific
+	//功能码:43/14
+	ReadDeviceIdentification(readCode, objectID byte) (objects map[byte]string, err error)
+}
+
+// DiagnosticsClient 串行链路诊断,仅适用于RTU/ASCII
+type DiagnosticsClient interface {
+	// ReadExceptionStatus 读异常状态
+	//功能码:7
+	ReadExceptionStatus() (status byte, err error)
+	// Diagnostics 诊断,返回响应中子功能码之后的数据
+	//功能码:8
+	Diagnostics(subFunction uint16, data []byte) (results []byte, err error)
+	// ReturnQueryData 回送查询数据,校验响应与请求一致
+	//功能码:8/0x00
+	ReturnQueryData(data []byte) (err error)
+	// RestartCommunications 重启通信,clearLog为true时同时清除通信事件记录
+	//功能码:8/0x01
+	RestartCommunications(clearLog bool) (err error)
+	// ReadDiagnosticRegister 读诊断寄存器
+	//功能码:8/0x02
+	ReadDiagnosticRegister() (value uint16, err error)
+	// ForceListenOnlyMode 强制从站进入只听模式,从站不响应,传输器需实现 BroadcastTransporter
+	//功能码:8/0x04
+	ForceListenOnlyMode() (err error)
+	// ClearCounters 清除计数器及诊断寄存器
+	//功能码:8/0x0A
+	ClearCounters() (err error)
+	// ReadDiagnosticCounter 读诊断计数器,subFunction 为 DiagBusMessageCount ~ DiagBusCharacterOverrunCount
+	//功能码:8
+	ReadDiagnosticCounter(subFunction uint16) (count uint16, err error)
+	// GetCommEventCounter 获取通信事件计数器
+	//功能码:11
+	GetCommEventCounter() (counter *CommEventCounter, err error)
+	// GetCommEventLog 获取通信事件记录
+	//功能码:12
+	GetCommEventLog() (log *CommEventLog, err error)
+	// ReportServerID 报告从站ID
+	//功能码:17
+	ReportServerID() (report *ServerIDReport, err error)
+}
+
+// FileRecordClient 读写文件记录
+type FileRecordClient interface {
+	// ReadFileRecord 读文件记录,按每项的 FileNumber、RecordNumber、Length 读取并返回填充 Data 后的副本
+	//超出协议数据单元长度时自动拆分为多次请求
+	//文件号:1~65535
+	//记录号:0~9999
+	//功能码:20
+	ReadFileRecord(records []FileRecord) (results []FileRecord, err error)
+	// WriteFileRecord 写文件记录,按每项的 FileNumber、RecordNumber 写入 Data
+	//超出协议数据单元长度时自动拆分为多次请求
+	//功能码:21
+	WriteFileRecord(records []FileRecord) (err error)
+}
+
+// RawClient 发送任意功能码
+type RawClient interface {
+	// SendRaw 发送任意功能码及数据,如厂商自定义功能码65~72、100~110
+	//响应按 RegisterFunctionDecoder 注册的规则解析,未注册时 results 的协议数据单元数据为功能码之后的全部数据
+	SendRaw(functionCode byte, data []byte) (request ApplicationDataUnit, results ApplicationDataUnit, err error)
+}
+
+var (
+	_ Client                     = (*ModbusClient)(nil)
+	_ ValueClient                = (*ModbusClient)(nil)
+	_ BroadcastClient            = (*ModbusClient)(nil)
+	_ MaskWriteRegisterClient    = (*ModbusClient)(nil)
+	_ ReadFIFOQueueClient        = (*ModbusClient)(nil)
+	_ DeviceIdentificationClient = (*ModbusClient)(nil)
+	_ DiagnosticsClient          = (*ModbusClient)(nil)
+	_ FileRecordClient           = (*ModbusClient)(nil)
+	_ RawClient                  = (*ModbusClient)(nil)
+)
+
+// ModbusClient 客户端,实现 Client 及各扩展接口,视图方法返回 *ModbusClient 以保留扩展功能
+type ModbusClient struct {
 	packager    Packager
 	transporter Transporter
+	ctx         context.Context
+	retry       *RetryPolicy
 }
 
-func (c *client) ReadCoils(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+func (c *ModbusClient) ReadCoils(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
 	if quantity < 1 || quantity > 2000 {
 		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 2000)
 		return
@@ -80,11 +209,9 @@ func (c *client) ReadCoils(address, quantity uint16) (request ApplicationDataUni
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
 }
-func (c *client) ReadDiscreteInputs(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+func (c *ModbusClient) ReadDiscreteInputs(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
 	if quantity < 1 || quantity > 2000 {
 		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v',", quantity, 1, 2000)
 		return
@@ -95,52 +222,23 @@ func (c *client) ReadDiscreteInputs(address, quantity uint16) (request Applicati
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
 }
-func (c *client) WriteSingleCoil(address uint16, value bool) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
-	if address > 65535 {
-		err = fmt.Errorf("modbus: address '%v' must be between '%v' and '%v'", address, 0, 65535)
+func (c *ModbusClient) WriteSingleCoil(address uint16, value bool) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	pdu, err := writeSingleCoilPDU(address, value)
+	if err != nil {
 		return
 	}
-	// The requested ON/OFF state can only be 0xFF00 and 0x0000
-	var coil uint16 = 0x0000
-	if value {
-		coil = 0xFF00
-	}
-	data := dataBlock(address, coil)
-	pdu := protocolDataUnit{
-		functionCode: FuncCodeWriteSingleCoil,
-		data:         data,
-		length:       len(data),
-	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
 }
-func (c *client) WriteMultipleCoils(address, quantity uint16, value []bool) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
-	if quantity < 1 || quantity > 1968 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 1968)
-		return
-	}
-	length := len(value)
-	if int(quantity) != length {
-		err = fmt.Errorf("modbus: quantity '%v' and value length '%v' are the same", quantity, length)
+func (c *ModbusClient) WriteMultipleCoils(address, quantity uint16, value []bool) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	pdu, err := writeMultipleCoilsPDU(address, quantity, value)
+	if err != nil {
 		return
 	}
-	coils := toBit(value)
-	data := dataBlockSuffix(coils, address, quantity)
-	pdu := protocolDataUnit{
-		functionCode: FuncCodeWriteMultipleCoils,
-		data:         data,
-		length:       len(data),
-	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
 }
-func (c *client) ReadInputRegisters(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+func (c *ModbusClient) ReadInputRegisters(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
 	if quantity < 1 || quantity > 125 {
 		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v',", quantity, 1, 125)
 		return
@@ -151,11 +249,9 @@ func (c *client) ReadInputRegisters(address, quantity uint16) (request Applicati
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
 }
-func (c *client) ReadHoldingRegisters(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+func (c *ModbusClient) ReadHoldingRegisters(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
 	if quantity < 1 || quantity > 125 {
 		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v',", quantity, 1, 125)
 		return
@@ -166,37 +262,84 @@ func (c *client) ReadHoldingRegisters(address, quantity uint16) (request Applica
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
 }
-func (c *client) WriteSingleRegister(address, value uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
-	data := dataBlock(address, value)
+func (c *ModbusClient) WriteSingleRegister(address, value uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	pdu, err := writeSingleRegisterPDU(address, value)
+	if err != nil {
+		return
+	}
+	return c.invoke(pdu)
+}
+func (c *ModbusClient) WriteMultipleRegisters(address, quantity uint16, value []byte) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	pdu, err := writeMultipleRegistersPDU(address, quantity, value)
+	if err != nil {
+		return
+	}
+	return c.invoke(pdu)
+}
+func (c *ModbusClient) MaskWriteRegister(address, andMask, orMask uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	data := dataBlock(address, andMask, orMask)
 	pdu := protocolDataUnit{
-		functionCode: FuncCodeWriteSingleRegister,
+		functionCode: FuncCodeMaskWriteRegister,
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
-}
-func (c *client) WriteMultipleRegisters(address, quantity uint16, value []byte) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
-	if quantity < 1 || quantity > 123 {
-		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v',", quantity, 1, 123)
+	request, results, err = c.invoke(pdu)
+	if err != nil {
 		return
 	}
-	data := dataBlockSuffix(value, address, quantity)
+	err = verifyEcho(request, results)
+	return
+}
+func (c *ModbusClient) ReadFIFOQueue(address uint16) (value []uint16, err error) {
+	data := dataBlock(address)
 	pdu := protocolDataUnit{
-		functionCode: FuncCodeWriteMultipleRegisters,
+		functionCode: FuncCodeReadFIFOQueue,
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	_, results, err := c.invoke(pdu)
+	if err != nil {
+		return
+	}
+	return FIFOQueueValues(results)
+}
+func (c *ModbusClient) ReadDeviceIdentification(readCode, objectID byte) (objects map[byte]string, err error) {
+	if readCode < ReadDeviceIDBasic || readCode > ReadDeviceIDSpecific {
+		err = fmt.Errorf("modbus: read device id code '%v' must be between '%v' and '%v'", readCode, ReadDeviceIDBasic, ReadDeviceIDSpecific)
+		return
+	}
+	objects = make(map[byte]string)
+	for {
+		data := []byte{meiReadDeviceIdentification, readCode, objectID}
+		pdu := protocolDataUnit{
+			functionCode: FuncCodeEncapsulatedInterface,
+			data:         data,
+			length:       len(data),
+		}
+		var results ApplicationDataUnit
+		if _, results, err = c.invoke(pdu); err != nil {
+			return
+		}
+		var id *deviceIdentification
+		if id, err = parseDeviceIdentification(results.GetMode(), results.GetPDU().GetData()); err != nil {
+			return
+		}
+		for k, v := range id.objects {
+			objects[k] = v
+		}
+		if !id.moreFollows || readCode == ReadDeviceIDSpecific {
+			return
+		}
+		if _, exist := objects[id.nextObjectID]; exist || len(id.objects) == 0 {
+			err = framingError(results.GetMode(), "device identification next object id '%X' does not advance", id.nextObjectID)
+			return
+		}
+		objectID = id.nextObjectID
+	}
 }
-func (c *client) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+func (c *ModbusClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
 	if readQuantity < 1 || readQuantity > 125 {
 		err = fmt.Errorf("modbus: quantity to read '%v' must be between '%v' and '%v',", readQuantity, 1, 125)
 		return
@@ -207,17 +350,135 @@ func (c *client) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddr
 	}
 	data := dataBlockSuffix(value, readAddress, readQuantity, writeAddress, writeQuantity)
 	pdu := protocolDataUnit{
-		functionCode: FuncCodeWriteMultipleRegisters,
+		functionCode: FuncCodeReadWriteMultipleRegisters,
 		data:         data,
 		length:       len(data),
 	}
-	request, err = c.packager.Encode(pdu)
-	results, err = c.Send(request)
-	return
+	return c.invoke(pdu)
+}
+
+func (c *ModbusClient) ReadCoilValues(address, quantity uint16) (value []bool, err error) {
+	_, results, err := c.ReadCoils(address, quantity)
+	if err != nil {
+		return
+	}
+	return BitValues(results, quantity)
+}
+func (c *ModbusClient) ReadDiscreteInputValues(address, quantity uint16) (value []bool, err error) {
+	_, results, err := c.ReadDiscreteInputs(address, quantity)
+	if err != nil {
+		return
+	}
+	return BitValues(results, quantity)
+}
+func (c *ModbusClient) ReadInputRegisterValues(address, quantity uint16) (value []uint16, err error) {
+	_, results, err := c.ReadInputRegisters(address, quantity)
+	if err != nil {
+		return
+	}
+	return RegisterValues(results, quantity)
+}
+func (c *ModbusClient) ReadHoldingRegisterValues(address, quantity uint16) (value []uint16, err error) {
+	_, results, err := c.ReadHoldingRegisters(address, quantity)
+	if err != nil {
+		return
+	}
+	return RegisterValues(results, quantity)
+}
+
+func (c *ModbusClient) BroadcastWriteSingleCoil(address uint16, value bool) (request ApplicationDataUnit, err error) {
+	pdu, err := writeSingleCoilPDU(address, value)
+	if err != nil {
+		return
+	}
+	return c.broadcast(pdu)
+}
+func (c *ModbusClient) BroadcastWriteMultipleCoils(address, quantity uint16, value []bool) (request ApplicationDataUnit, err error) {
+	pdu, err := writeMultipleCoilsPDU(address, quantity, value)
+	if err != nil {
+		return
+	}
+	return c.broadcast(pdu)
+}
+func (c *ModbusClient) BroadcastWriteSingleRegister(address, value uint16) (request ApplicationDataUnit, err error) {
+	pdu, err := writeSingleRegisterPDU(address, value)
+	if err != nil {
+		return
+	}
+	return c.broadcast(pdu)
+}
+func (c *ModbusClient) BroadcastWriteMultipleRegisters(address, quantity uint16, value []byte) (request ApplicationDataUnit, err error) {
+	pdu, err := writeMultipleRegistersPDU(address, quantity, value)
+	if err != nil {
+		return
+	}
+	return c.broadcast(pdu)
 }
 
 var complementary = []byte{0x1, 0x2, 0x4, 0x8, 0x10, 0x20, 0x40, 0x80}
 
+func writeSingleCoilPDU(address uint16, value bool) (pdu protocolDataUnit, err error) {
+	if address > 65535 {
+		err = fmt.Errorf("modbus: address '%v' must be between '%v' and '%v'", address, 0, 65535)
+		return
+	}
+	// The requested ON/OFF state can only be 0xFF00 and 0x0000
+	var coil uint16 = 0x0000
+	if value {
+		coil = 0xFF00
+	}
+	data := dataBlock(address, coil)
+	pdu = protocolDataUnit{
+		functionCode: FuncCodeWriteSingleCoil,
+		data:         data,
+		length:       len(data),
+	}
+	return
+}
+
+func writeMultipleCoilsPDU(address, quantity uint16, value []bool) (pdu protocolDataUnit, err error) {
+	if quantity < 1 || quantity > 1968 {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", quantity, 1, 1968)
+		return
+	}
+	length := len(value)
+	if int(quantity) != length {
+		err = fmt.Errorf("modbus: quantity '%v' and value length '%v' are the same", quantity, length)
+		return
+	}
+	coils := toBit(value)
+	data := dataBlockSuffix(coils, address, quantity)
+	pdu = protocolDataUnit{
+		functionCode: FuncCodeWriteMultipleCoils,
+		data:         data,
+		length:       len(data),
+	}
+	return
+}
+
+func writeSingleRegisterPDU(address, value uint16) (pdu protocolDataUnit, err error) {
+	data := dataBlock(address, value)
+	pdu = protocolDataUnit{
+		functionCode: FuncCodeWriteSingleRegister,
+		data:         data,
+		length:       len(data),
+	}
+	return
+}
+
+func writeMultipleRegistersPDU(address, quantity uint16, value []byte) (pdu protocolDataUnit, err error) {
+	if quantity < 1 || quantity > 123 {
+		err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v',", quantity, 1, 123)
+		return
+	}
+	data := dataBlockSuffix(value, address, quantity)
+	pdu = protocolDataUnit{
+		functionCode: FuncCodeWriteMultipleRegisters,
+		data:         data,
+		length:       len(data),
+	}
+	return
+}
 func toBit(value []bool) []byte {
 	l := len(value)
 	length := l / 8
@@ -236,6 +497,13 @@ func toBit(value []bool) []byte {
 	}
 	return bi
 }
+func fromBit(data []byte, quantity int) []bool {
+	value := make([]bool, quantity)
+	for i := 0; i < quantity && i/8 < len(data); i++ {
+		value[i] = data[i/8]&complementary[i%8] != 0
+	}
+	return value
+}
 func dataBlock(value ...uint16) []byte {
 	data := make([]byte, 2*len(value))
 	for i, v := range value {
@@ -253,8 +521,57 @@ func dataBlockSuffix(suffix []byte, value ...uint16) []byte {
 	copy(data[length+1:], suffix)
 	return data
 }
-func (c *client) Send(request ApplicationDataUnit) (results ApplicationDataUnit, err error) {
-	bys, err := c.transporter.Send(request)
+
+// invoke 编码协议数据单元并发送
+func (c *ModbusClient) invoke(pdu ProtocolDataUnit) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	request, err = c.packager.Encode(pdu)
+	if err != nil {
+		return
+	}
+	results, err = c.Send(request)
+	return
+}
+
+func (c *ModbusClient) SendRaw(functionCode byte, data []byte) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	if functionCode == 0 || functionCode&0x80 != 0 {
+		err = fmt.Errorf("modbus: function code '%v' must be between '%v' and '%v'", functionCode, 1, 127)
+		return
+	}
+	return c.invoke(NewProtocolDataUnit(functionCode, data))
+}
+
+// broadcast 以从站地址0编码并发送,不等待响应
+func (c *ModbusClient) broadcast(pdu ProtocolDataUnit) (request ApplicationDataUnit, err error) {
+	request, err = c.packager.ForUnit(0).Encode(pdu)
+	if err != nil {
+		return
+	}
+	err = c.post(request)
+	return
+}
+
+// post 发送请求,不等待响应
+func (c *ModbusClient) post(request ApplicationDataUnit) (err error) {
+	t, ok := c.transporter.(BroadcastTransporter)
+	if !ok {
+		return fmt.Errorf("modbus: transporter '%T' does not support broadcast", c.transporter)
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.Broadcast(ctx, request)
+}
+func (c *ModbusClient) Send(request ApplicationDataUnit) (results ApplicationDataUnit, err error) {
+	if c.retry != nil {
+		return c.sendWithRetry(request)
+	}
+	return c.exchange(request)
+}
+
+// exchange 发送一次请求并解析、校验响应
+func (c *ModbusClient) exchange(request ApplicationDataUnit) (results ApplicationDataUnit, err error) {
+	bys, err := c.send(request)
 	if err != nil {
 		return
 	}
@@ -265,8 +582,50 @@ func (c *client) Send(request ApplicationDataUnit) (results ApplicationDataUnit,
 	err = c.packager.Verify(request, results)
 	return
 }
+func (c *ModbusClient) send(request ApplicationDataUnit) (aduResponse []byte, err error) {
+	if c.ctx == nil {
+		return c.transporter.Send(request)
+	}
+	if t, ok := c.transporter.(ContextTransporter); ok {
+		return t.SendContext(c.ctx, request)
+	}
+	if err = c.ctx.Err(); err != nil {
+		return
+	}
+	return c.transporter.Send(request)
+}
+
+// ForUnit 返回访问指定从站的客户端视图,视图与原客户端共享传输器、锁及TCP事务计数
+func (c *ModbusClient) ForUnit(slaveID byte) *ModbusClient {
+	view := *c
+	view.packager = c.packager.ForUnit(slaveID)
+	return &view
+}
+
+// WithContext 返回共享传输器的客户端视图,视图上的请求在ctx取消或超时时中止
+// 传输器需实现 ContextTransporter 才能中止进行中的读写
+func (c *ModbusClient) WithContext(ctx context.Context) *ModbusClient {
+	if ctx == nil {
+		panic("modbus: nil context")
+	}
+	view := *c
+	view.ctx = ctx
+	return &view
+}
+
+// WithRetry 返回共享传输器的客户端视图,视图上的请求按重试策略重发,policy为nil时不重试
+func (c *ModbusClient) WithRetry(policy *RetryPolicy) *ModbusClient {
+	view := *c
+	view.retry = policy
+	return &view
+}
 func NewClient(packager Packager, transporter Transporter) (c Client) {
-	c = &client{
+	return NewModbusClient(packager, transporter)
+}
+
+// NewModbusClient 创建客户端,与 NewClient 相同但返回具体类型,可直接使用扩展功能
+func NewModbusClient(packager Packager, transporter Transporter) (c *ModbusClient) {
+	c = &ModbusClient{
 		packager:    packager,
 		transporter: transporter,
 	}
@@ -0,0 +1,272 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ByteOrder 多寄存器数据的字节序,A表示数据的最高字节
+// 64位数据按相同规则扩展,如 CDAB 表示寄存器按低字在前排列、寄存器内高字节在前
+// 与 encoding/binary 一致,解析方法不校验长度,寄存器不足2个(32位)或4个(64位)时panic,响应应先经 RegisterValues 校验
+type ByteOrder int
+
+const (
+	// ABCD 大端,高字在前,字内高字节在前
+	ABCD ByteOrder = iota
+	// CDAB 低字在前,字内高字节在前
+	CDAB
+	// BADC 高字在前,字内低字节在前
+	BADC
+	// DCBA 小端,低字在前,字内低字节在前
+	DCBA
+)
+
+func (o ByteOrder) String() string {
+	switch o {
+	case ABCD:
+		return "ABCD"
+	case CDAB:
+		return "CDAB"
+	case BADC:
+		return "BADC"
+	case DCBA:
+		return "DCBA"
+	}
+	return fmt.Sprintf("ByteOrder(%d)", int(o))
+}
+
+// ParseByteOrder 解析字节序名称,不区分大小写
+func ParseByteOrder(name string) (o ByteOrder, err error) {
+	switch strings.ToUpper(name) {
+	case "ABCD":
+		return ABCD, nil
+	case "CDAB":
+		return CDAB, nil
+	case "BADC":
+		return BADC, nil
+	case "DCBA":
+		return DCBA, nil
+	}
+	return ABCD, fmt.Errorf("modbus: unknown byte order '%v'", name)
+}
+
+func (o ByteOrder) wordSwap() bool {
+	return o == CDAB || o == DCBA
+}
+
+func (o ByteOrder) byteSwap() bool {
+	return o == BADC || o == DCBA
+}
+
+// bytes 将寄存器值按字节序还原为大端字节
+func (o ByteOrder) bytes(value []uint16) []byte {
+	n := len(value)
+	data := make([]byte, 2*n)
+	for i, v := range value {
+		j := i
+		if o.wordSwap() {
+			j = n - 1 - i
+		}
+		if o.byteSwap() {
+			v = v<<8 | v>>8
+		}
+		binary.BigEndian.PutUint16(data[2*j:], v)
+	}
+	return data
+}
+
+// registers 将大端字节按字节序排列为寄存器值
+func (o ByteOrder) registers(data []byte) []uint16 {
+	n := len(data) / 2
+	value := make([]uint16, n)
+	for j := 0; j < n; j++ {
+		i := j
+		if o.wordSwap() {
+			i = n - 1 - j
+		}
+		v := binary.BigEndian.Uint16(data[2*j:])
+		if o.byteSwap() {
+			v = v<<8 | v>>8
+		}
+		value[i] = v
+	}
+	return value
+}
+
+// Uint32 从2个寄存器解析uint32
+func (o ByteOrder) Uint32(value []uint16) uint32 {
+	return binary.BigEndian.Uint32(o.bytes(value[:2]))
+}
+
+// Int32 从2个寄存器解析int32
+func (o ByteOrder) Int32(value []uint16) int32 {
+	return int32(o.Uint32(value))
+}
+
+// Float32 从2个寄存器解析float32
+func (o ByteOrder) Float32(value []uint16) float32 {
+	return math.Float32frombits(o.Uint32(value))
+}
+
+// Uint64 从4个寄存器解析uint64
+func (o ByteOrder) Uint64(value []uint16) uint64 {
+	return binary.BigEndian.Uint64(o.bytes(value[:4]))
+}
+
+// Int64 从4个寄存器解析int64
+func (o ByteOrder) Int64(value []uint16) int64 {
+	return int64(o.Uint64(value))
+}
+
+// Float64 从4个寄存器解析float64
+func (o ByteOrder) Float64(value []uint16) float64 {
+	return math.Float64frombits(o.Uint64(value))
+}
+
+// PutUint32 将uint32编码为2个寄存器
+func (o ByteOrder) PutUint32(v uint32) []uint16 {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, v)
+	return o.registers(data)
+}
+
+// PutInt32 将int32编码为2个寄存器
+func (o ByteOrder) PutInt32(v int32) []uint16 {
+	return o.PutUint32(uint32(v))
+}
+
+// PutFloat32 将float32编码为2个寄存器
+func (o ByteOrder) PutFloat32(v float32) []uint16 {
+	return o.PutUint32(math.Float32bits(v))
+}
+
+// PutUint64 将uint64编码为4个寄存器
+func (o ByteOrder) PutUint64(v uint64) []uint16 {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, v)
+	return o.registers(data)
+}
+
+// PutInt64 将int64编码为4个寄存器
+func (o ByteOrder) PutInt64(v int64) []uint16 {
+	return o.PutUint64(uint64(v))
+}
+
+// PutFloat64 将float64编码为4个寄存器
+func (o ByteOrder) PutFloat64(v float64) []uint16 {
+	return o.PutUint64(math.Float64bits(v))
+}
+
+// RegisterCodec 在 Client 之上按指定字节序读写保持寄存器中的多寄存器数据类型
+type RegisterCodec struct {
+	Client Client
+	Order  ByteOrder
+}
+
+func (r *RegisterCodec) read(address, quantity uint16) (value []uint16, err error) {
+	_, results, err := r.Client.ReadHoldingRegisters(address, quantity)
+	if err != nil {
+		return
+	}
+	return RegisterValues(results, quantity)
+}
+
+func (r *RegisterCodec) write(address uint16, value []uint16) (err error) {
+	_, _, err = r.Client.WriteMultipleRegisters(address, uint16(len(value)), dataBlock(value...))
+	return
+}
+
+// ReadUint32 读uint32,占用2个寄存器
+func (r *RegisterCodec) ReadUint32(address uint16) (v uint32, err error) {
+	value, err := r.read(address, 2)
+	if err != nil {
+		return
+	}
+	return r.Order.Uint32(value), nil
+}
+
+// ReadInt32 读int32,占用2个寄存器
+func (r *RegisterCodec) ReadInt32(address uint16) (v int32, err error) {
+	value, err := r.read(address, 2)
+	if err != nil {
+		return
+	}
+	return r.Order.Int32(value), nil
+}
+
+// ReadFloat32 读float32,占用2个寄存器
+func (r *RegisterCodec) ReadFloat32(address uint16) (v float32, err error) {
+	value, err := r.read(address, 2)
+	if err != nil {
+		return
+	}
+	return r.Order.Float32(value), nil
+}
+
+// ReadUint64 读uint64,占用4个寄存器
+func (r *RegisterCodec) ReadUint64(address uint16) (v uint64, err error) {
+	value, err := r.read(address, 4)
+	if err != nil {
+		return
+	}
+	return r.Order.Uint64(value), nil
+}
+
+// ReadInt64 读int64,占用4个寄存器
+func (r *RegisterCodec) ReadInt64(address uint16) (v int64, err error) {
+	value, err := r.read(address, 4)
+	if err != nil {
+		return
+	}
+	return r.Order.Int64(value), nil
+}
+
+// ReadFloat64 读float64,占用4个寄存器
+func (r *RegisterCodec) ReadFloat64(address uint16) (v float64, err error) {
+	value, err := r.read(address, 4)
+	if err != nil {
+		return
+	}
+	return r.Order.Float64(value), nil
+}
+
+// WriteUint32 写uint32,占用2个寄存器
+func (r *RegisterCodec) WriteUint32(address uint16, v uint32) error {
+	return r.write(address, r.Order.PutUint32(v))
+}
+
+// WriteInt32 写int32,占用2个寄存器
+func (r *RegisterCodec) WriteInt32(address uint16, v int32) error {
+	return r.write(address, r.Order.PutInt32(v))
+}
+
+// WriteFloat32 写float32,占用2个寄存器
+func (r *RegisterCodec) WriteFloat32(address uint16, v float32) error {
+	return r.write(address, r.Order.PutFloat32(v))
+}
+
+// WriteUint64 写uint64,占用4个寄存器
+func (r *RegisterCodec) WriteUint64(address uint16, v uint64) error {
+	return r.write(address, r.Order.PutUint64(v))
+}
+
+// WriteInt64 写int64,占用4个寄存器
+func (r *RegisterCodec) WriteInt64(address uint16, v int64) error {
+	return r.write(address, r.Order.PutInt64(v))
+}
+
+// WriteFloat64 写float64,占用4个寄存器
+func (r *RegisterCodec) WriteFloat64(address uint16, v float64) error {
+	return r.write(address, r.Order.PutFloat64(v))
+}
+
+// NewRegisterCodec 创建多寄存器数据类型读写器
+func NewRegisterCodec(c Client, order ByteOrder) (r *RegisterCodec) {
+	r = &RegisterCodec{
+		Client: c,
+		Order:  order,
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestByteOrder(t *testing.T) {
+	cases := []struct {
+		order  ByteOrder
+		value  []uint16
+		value8 []uint16
+	}{
+		{ABCD, []uint16{0x1122, 0x3344}, []uint16{0x1122, 0x3344, 0x5566, 0x7788}},
+		{CDAB, []uint16{0x3344, 0x1122}, []uint16{0x7788, 0x5566, 0x3344, 0x1122}},
+		{BADC, []uint16{0x2211, 0x4433}, []uint16{0x2211, 0x4433, 0x6655, 0x8877}},
+		{DCBA, []uint16{0x4433, 0x2211}, []uint16{0x8877, 0x6655, 0x4433, 0x2211}},
+	}
+	for _, c := range cases {
+		if v := c.order.Uint32(c.value); v != 0x11223344 {
+			t.Fatalf("%s: unexpected uint32 %x", c.order, v)
+		}
+		if v := c.order.PutUint32(0x11223344); v[0] != c.value[0] || v[1] != c.value[1] {
+			t.Fatalf("%s: unexpected registers %x", c.order, v)
+		}
+		if v := c.order.Uint64(c.value8); v != 0x1122334455667788 {
+			t.Fatalf("%s: unexpected uint64 %x", c.order, v)
+		}
+		if v := c.order.Float64(c.order.PutFloat64(-12.5)); v != -12.5 {
+			t.Fatalf("%s: unexpected float64 %v", c.order, v)
+		}
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	_, address := startTestServer(t, NewStoreHandler(NewMemoryDataStore(0, 0, 0, 16)))
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	r := NewRegisterCodec(NewClient(NewTcpPackager(1), st), CDAB)
+	if err := r.WriteFloat32(0, 230.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteInt64(2, -42); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.ReadFloat32(0); err != nil || v != 230.5 {
+		t.Fatalf("unexpected float32 %v %v", v, err)
+	}
+	if v, err := r.ReadInt64(2); err != nil || v != -42 {
+		t.Fatalf("unexpected int64 %v %v", v, err)
+	}
+}
+
+// 响应寄存器数量不足时返回错误,不调用 ByteOrder 解析方法
+func TestRegisterCodecShortResponse(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{
+		respondPDU(NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 1})),
+	}}
+	r := NewRegisterCodec(NewClient(NewTcpPackager(1), st), ABCD)
+	if _, err := r.ReadFloat64(0); !errors.As(err, new(*FramingError)) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// startSilentServer 启动只接收不响应的tcp服务
+func startSilentServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				buf := make([]byte, tcpMaxSize)
+				for {
+					if _, err := conn.Read(buf); err != nil {
+						_ = conn.Close()
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestTcpContextCancel(t *testing.T) {
+	st := NewTcpTransporter(startSilentServer(t))
+	st.ReadTimeout = 10 * time.Second
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	// 第二个请求等待传输器锁时超时
+	waited := make(chan error, 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, _, err := c.WithContext(ctx).ReadCoils(0, 1)
+		waited <- err
+	}()
+	start := time.Now()
+	_, _, err := c.WithContext(ctx).ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancel took %v", elapsed)
+	}
+	if err = <-waited; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if st.Connected() {
+		t.Fatal("connection should be closed after cancel")
+	}
+}
+
+func TestTcpContextDeadline(t *testing.T) {
+	st := NewTcpTransporter(startSilentServer(t))
+	st.ReadTimeout = 10 * time.Second
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := c.WithContext(ctx).ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSerialContextCancel(t *testing.T) {
+	port := newTestPort()
+	st := NewSerialTransporter("COM1")
+	st.port = port
+	port.timeout = 10 * time.Second
+	c := NewModbusClient(NewRtuPackager(1), st)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := c.WithContext(ctx).ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if st.Connected() {
+		t.Fatal("port should be closed after cancel")
+	}
+}
@@ -0,0 +1,293 @@
+package modbus
+
+import (
+	"sync"
+)
+
+// DataStore 从站数据存储接口,包含线圈、离散量输入、输入寄存器、保持寄存器四张数据表
+// 地址越界时返回 ExceptionIllegalDataAddress,数量非法时返回 ExceptionIllegalDataValue
+type DataStore interface {
+	ReadCoils(address, quantity uint16) (value []bool, err error)
+	WriteCoils(address uint16, value []bool) (err error)
+	ReadDiscreteInputs(address, quantity uint16) (value []bool, err error)
+	WriteDiscreteInputs(address uint16, value []bool) (err error)
+	ReadInputRegisters(address, quantity uint16) (value []uint16, err error)
+	WriteInputRegisters(address uint16, value []uint16) (err error)
+	ReadHoldingRegisters(address, quantity uint16) (value []uint16, err error)
+	WriteHoldingRegisters(address uint16, value []uint16) (err error)
+}
+
+// memoryDataStore 内存数据存储,并发安全
+type memoryDataStore struct {
+	mu               sync.RWMutex
+	coils            []bool
+	discreteInputs   []bool
+	inputRegisters   []uint16
+	holdingRegisters []uint16
+}
+
+func (s *memoryDataStore) ReadCoils(address, quantity uint16) (value []bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readTable(s.coils, address, int(quantity))
+}
+func (s *memoryDataStore) WriteCoils(address uint16, value []bool) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeTable(s.coils, address, value)
+}
+func (s *memoryDataStore) ReadDiscreteInputs(address, quantity uint16) (value []bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readTable(s.discreteInputs, address, int(quantity))
+}
+func (s *memoryDataStore) WriteDiscreteInputs(address uint16, value []bool) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeTable(s.discreteInputs, address, value)
+}
+func (s *memoryDataStore) ReadInputRegisters(address, quantity uint16) (value []uint16, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readTable(s.inputRegisters, address, int(quantity))
+}
+func (s *memoryDataStore) WriteInputRegisters(address uint16, value []uint16) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeTable(s.inputRegisters, address, value)
+}
+func (s *memoryDataStore) ReadHoldingRegisters(address, quantity uint16) (value []uint16, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readTable(s.holdingRegisters, address, int(quantity))
+}
+func (s *memoryDataStore) WriteHoldingRegisters(address uint16, value []uint16) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeTable(s.holdingRegisters, address, value)
+}
+
+// MaskWriteHoldingRegister 屏蔽写保持寄存器,读改写过程持有写锁
+func (s *memoryDataStore) MaskWriteHoldingRegister(address, andMask, orMask uint16) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, err := readTable(s.holdingRegisters, address, 1)
+	if err != nil {
+		return
+	}
+	return writeTable(s.holdingRegisters, address, []uint16{maskRegister(value[0], andMask, orMask)})
+}
+
+// holdingRegisterMasker 支持原子屏蔽写保持寄存器的数据存储
+type holdingRegisterMasker interface {
+	MaskWriteHoldingRegister(address, andMask, orMask uint16) (err error)
+}
+
+// ReadWriteHoldingRegisters 先写后读保持寄存器,读范围校验、写入及读取过程持有写锁
+func (s *memoryDataStore) ReadWriteHoldingRegisters(readAddress, readQuantity, writeAddress uint16, value []uint16) (results []uint16, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = readTable(s.holdingRegisters, readAddress, int(readQuantity)); err != nil {
+		return
+	}
+	if err = writeTable(s.holdingRegisters, writeAddress, value); err != nil {
+		return
+	}
+	return readTable(s.holdingRegisters, readAddress, int(readQuantity))
+}
+
+// holdingRegisterReadWriter 支持原子读/写多个保持寄存器的数据存储
+type holdingRegisterReadWriter interface {
+	ReadWriteHoldingRegisters(readAddress, readQuantity, writeAddress uint16, value []uint16) (results []uint16, err error)
+}
+
+// maskRegister 计算屏蔽写结果 (value AND andMask) OR (orMask AND (NOT andMask))
+func maskRegister(value, andMask, orMask uint16) uint16 {
+	return value&andMask | orMask&^andMask
+}
+
+func readTable[T bool | uint16](table []T, address uint16, quantity int) (value []T, err error) {
+	if quantity < 1 {
+		return nil, ExceptionIllegalDataValue
+	}
+	if int(address)+quantity > len(table) {
+		return nil, ExceptionIllegalDataAddress
+	}
+	value = make([]T, quantity)
+	copy(value, table[address:])
+	return
+}
+
+func writeTable[T bool | uint16](table []T, address uint16, value []T) (err error) {
+	if len(value) < 1 {
+		return ExceptionIllegalDataValue
+	}
+	if int(address)+len(value) > len(table) {
+		return ExceptionIllegalDataAddress
+	}
+	copy(table[address:], value)
+	return
+}
+
+// NewMemoryDataStore 创建内存数据存储,参数为各数据表的大小(0~65536)
+func NewMemoryDataStore(coils, discreteInputs, inputRegisters, holdingRegisters int) (s DataStore) {
+	s = &memoryDataStore{
+		coils:            make([]bool, coils),
+		discreteInputs:   make([]bool, discreteInputs),
+		inputRegisters:   make([]uint16, inputRegisters),
+		holdingRegisters: make([]uint16, holdingRegisters),
+	}
+	return
+}
+
+// StoreHandler 基于数据存储的请求处理器,按从站地址选择数据存储,可在一个监听上模拟多个从站
+type StoreHandler struct {
+	// Default 未单独设置数据存储的从站地址使用的数据存储,为nil时响应网关目标设备响应失败
+	Default DataStore
+	// Identification 设备识别码对象,所有从站地址共用,为nil时不支持读设备识别码
+	Identification map[byte]string
+	mu             sync.RWMutex
+	stores         map[byte]DataStore
+}
+
+// SetStore 设置从站地址对应的数据存储,store为nil时删除
+func (h *StoreHandler) SetStore(slaveID byte, store DataStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if store == nil {
+		delete(h.stores, slaveID)
+		return
+	}
+	if h.stores == nil {
+		h.stores = make(map[byte]DataStore)
+	}
+	h.stores[slaveID] = store
+}
+
+// Store 获取从站地址对应的数据存储
+func (h *StoreHandler) Store(slaveID byte) (store DataStore, exist bool) {
+	h.mu.RLock()
+	store, exist = h.stores[slaveID]
+	h.mu.RUnlock()
+	if !exist && h.Default != nil {
+		return h.Default, true
+	}
+	return
+}
+
+func (h *StoreHandler) store(slaveID byte) (DataStore, error) {
+	store, exist := h.Store(slaveID)
+	if !exist {
+		return nil, ExceptionGatewayTargetFailed
+	}
+	return store, nil
+}
+
+func (h *StoreHandler) ReadCoils(slaveID byte, address, quantity uint16) (value []bool, err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.ReadCoils(address, quantity)
+}
+func (h *StoreHandler) ReadDiscreteInputs(slaveID byte, address, quantity uint16) (value []bool, err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.ReadDiscreteInputs(address, quantity)
+}
+func (h *StoreHandler) WriteSingleCoil(slaveID byte, address uint16, value bool) (err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.WriteCoils(address, []bool{value})
+}
+func (h *StoreHandler) WriteMultipleCoils(slaveID byte, address uint16, value []bool) (err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.WriteCoils(address, value)
+}
+func (h *StoreHandler) ReadInputRegisters(slaveID byte, address, quantity uint16) (value []uint16, err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.ReadInputRegisters(address, quantity)
+}
+func (h *StoreHandler) ReadHoldingRegisters(slaveID byte, address, quantity uint16) (value []uint16, err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.ReadHoldingRegisters(address, quantity)
+}
+func (h *StoreHandler) WriteSingleRegister(slaveID byte, address, value uint16) (err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.WriteHoldingRegisters(address, []uint16{value})
+}
+func (h *StoreHandler) WriteMultipleRegisters(slaveID byte, address uint16, value []uint16) (err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	return store.WriteHoldingRegisters(address, value)
+}
+
+// MaskWriteRegister 屏蔽写寄存器,数据存储不支持原子屏蔽写时以读改写方式执行
+func (h *StoreHandler) MaskWriteRegister(slaveID byte, address, andMask, orMask uint16) (err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	if m, ok := store.(holdingRegisterMasker); ok {
+		return m.MaskWriteHoldingRegister(address, andMask, orMask)
+	}
+	value, err := store.ReadHoldingRegisters(address, 1)
+	if err != nil {
+		return
+	}
+	return store.WriteHoldingRegisters(address, []uint16{maskRegister(value[0], andMask, orMask)})
+}
+func (h *StoreHandler) ReadWriteMultipleRegisters(slaveID byte, readAddress, readQuantity, writeAddress uint16, value []uint16) (results []uint16, err error) {
+	store, err := h.store(slaveID)
+	if err != nil {
+		return
+	}
+	if rw, ok := store.(holdingRegisterReadWriter); ok {
+		return rw.ReadWriteHoldingRegisters(readAddress, readQuantity, writeAddress, value)
+	}
+	// 先校验读范围,避免读越界时写操作已生效;写操作先于读操作执行
+	if _, err = store.ReadHoldingRegisters(readAddress, readQuantity); err != nil {
+		return
+	}
+	if err = store.WriteHoldingRegisters(writeAddress, value); err != nil {
+		return
+	}
+	return store.ReadHoldingRegisters(readAddress, readQuantity)
+}
+
+// DeviceIdentification 返回设备识别码对象
+func (h *StoreHandler) DeviceIdentification(slaveID byte) (objects map[byte]string, err error) {
+	if _, err = h.store(slaveID); err != nil {
+		return
+	}
+	if h.Identification == nil {
+		return nil, ExceptionIllegalFunction
+	}
+	return h.Identification, nil
+}
+
+// NewStoreHandler 创建基于数据存储的请求处理器,store为所有从站地址默认使用的数据存储
+func NewStoreHandler(store DataStore) (h *StoreHandler) {
+	h = &StoreHandler{
+		Default: store,
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoryDataStoreRange(t *testing.T) {
+	s := NewMemoryDataStore(8, 8, 4, 4)
+	if err := s.WriteHoldingRegisters(2, []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteHoldingRegisters(3, []uint16{1, 2}); !errors.Is(err, ExceptionIllegalDataAddress) {
+		t.Fatalf("expected illegal data address, got %v", err)
+	}
+	if _, err := s.ReadCoils(0, 0); !errors.Is(err, ExceptionIllegalDataValue) {
+		t.Fatalf("expected illegal data value, got %v", err)
+	}
+	value, err := s.ReadHoldingRegisters(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value[0] != 0 || value[1] != 1 || value[2] != 2 {
+		t.Fatalf("unexpected registers %v", value)
+	}
+}
+
+func TestStoreHandlerUnits(t *testing.T) {
+	h := NewStoreHandler(nil)
+	h.SetStore(1, NewMemoryDataStore(0, 0, 0, 10))
+	h.SetStore(2, NewMemoryDataStore(0, 0, 0, 10))
+	response := servePDU(h, 1, protocolDataUnit{functionCode: FuncCodeWriteSingleRegister, data: dataBlock(0, 11)})
+	if response.GetFunctionCode() != FuncCodeWriteSingleRegister {
+		t.Fatalf("unexpected response '%X' %v", response.GetFunctionCode(), response.GetData())
+	}
+	response = servePDU(h, 2, protocolDataUnit{functionCode: FuncCodeReadHoldingRegisters, data: dataBlock(0, 1)})
+	if value := registers(response.GetData()[1:]); value[0] != 0 {
+		t.Fatalf("unit 2 should not see unit 1 writes: %v", value)
+	}
+	response = servePDU(h, 3, protocolDataUnit{functionCode: FuncCodeReadHoldingRegisters, data: dataBlock(0, 1)})
+	if response.GetData()[0] != byte(ExceptionGatewayTargetFailed) {
+		t.Fatalf("unexpected response '%X' %v", response.GetFunctionCode(), response.GetData())
+	}
+	response = servePDU(h, 1, protocolDataUnit{functionCode: FuncCodeReadHoldingRegisters, data: dataBlock(8, 3)})
+	if response.GetFunctionCode() != FuncCodeReadHoldingRegisters|0x80 || response.GetData()[0] != byte(ExceptionIllegalDataAddress) {
+		t.Fatalf("unexpected response '%X' %v", response.GetFunctionCode(), response.GetData())
+	}
+}
+
+func TestClientForUnit(t *testing.T) {
+	h := NewStoreHandler(NewMemoryDataStore(0, 0, 0, 4))
+	unit := NewMemoryDataStore(0, 0, 0, 4)
+	h.SetStore(2, unit)
+	_, address := startTestServer(t, h)
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	if _, _, err := c.ForUnit(2).WriteSingleRegister(1, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	value, err := unit.ReadHoldingRegisters(1, 1)
+	if err != nil || value[0] != 0x1234 {
+		t.Fatalf("unexpected unit 2 registers %v %v", value, err)
+	}
+	value, err = c.ReadHoldingRegisterValues(1, 1)
+	if err != nil || value[0] != 0 {
+		t.Fatalf("unexpected default registers %v %v", value, err)
+	}
+	if _, _, err = c.ForUnit(3).WriteSingleRegister(1, 0x5678); err != nil {
+		t.Fatal(err)
+	}
+	value, err = c.ReadHoldingRegisterValues(1, 1)
+	if err != nil || value[0] != 0x5678 {
+		t.Fatalf("unit without store should use default store %v %v", value, err)
+	}
+}
+
+func TestMaskWriteRegister(t *testing.T) {
+	store := NewMemoryDataStore(0, 0, 0, 4)
+	_ = store.WriteHoldingRegisters(0, []uint16{0x0012})
+	_, address := startTestServer(t, NewStoreHandler(store))
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	if _, _, err := c.MaskWriteRegister(0, 0x00F2, 0x0025); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := store.ReadHoldingRegisters(0, 1)
+	if value[0] != 0x0017 {
+		t.Fatalf("unexpected register %04X", value[0])
+	}
+	if _, _, err := c.MaskWriteRegister(4, 0, 0); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestMaskWriteRegisterUnsupported(t *testing.T) {
+	response := servePDU(&testHandler{registers: make([]uint16, 4)}, 1, protocolDataUnit{
+		functionCode: FuncCodeMaskWriteRegister,
+		data:         dataBlock(0, 0xFFFF, 0),
+	})
+	if response.GetFunctionCode() != FuncCodeMaskWriteRegister|0x80 || response.GetData()[0] != byte(ExceptionIllegalFunction) {
+		t.Fatalf("unexpected response '%X' %v", response.GetFunctionCode(), response.GetData())
+	}
+}
+
+// plainStore 仅实现 DataStore,不支持原子读/写多个寄存器
+type plainStore struct {
+	DataStore
+}
+
+func TestStoreHandlerReadWriteMultipleRegisters(t *testing.T) {
+	for _, store := range []DataStore{NewMemoryDataStore(0, 0, 0, 4), plainStore{NewMemoryDataStore(0, 0, 0, 4)}} {
+		h := NewStoreHandler(store)
+		// 读越界时不写入
+		if _, err := h.ReadWriteMultipleRegisters(1, 2, 3, 0, []uint16{7}); !errors.Is(err, ExceptionIllegalDataAddress) {
+			t.Fatalf("%T: expected illegal data address, got %v", store, err)
+		}
+		if value, _ := store.ReadHoldingRegisters(0, 1); value[0] != 0 {
+			t.Fatalf("%T: unexpected write %v", store, value)
+		}
+		results, err := h.ReadWriteMultipleRegisters(1, 0, 2, 1, []uint16{7})
+		if err != nil || results[0] != 0 || results[1] != 7 {
+			t.Fatalf("%T: unexpected registers %v %v", store, results, err)
+		}
+	}
+}
+
+// readWriterStore 记录原子读/写多个寄存器的调用
+type readWriterStore struct {
+	DataStore
+	calls int
+}
+
+func (s *readWriterStore) ReadWriteHoldingRegisters(readAddress, readQuantity, writeAddress uint16, value []uint16) ([]uint16, error) {
+	s.calls++
+	return s.DataStore.(holdingRegisterReadWriter).ReadWriteHoldingRegisters(readAddress, readQuantity, writeAddress, value)
+}
+
+func TestStoreHandlerReadWriteMultipleRegistersAtomic(t *testing.T) {
+	store := &readWriterStore{DataStore: NewMemoryDataStore(0, 0, 0, 4)}
+	results, err := NewStoreHandler(store).ReadWriteMultipleRegisters(1, 0, 2, 1, []uint16{7})
+	if err != nil || results[1] != 7 || store.calls != 1 {
+		t.Fatalf("unexpected registers %v %v, calls '%v'", results, err, store.calls)
+	}
+}
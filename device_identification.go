@@ -0,0 +1,170 @@
+package modbus
+
+import (
+	"sort"
+)
+
+// meiReadDeviceIdentification 封装接口传输类型:读设备识别码
+const meiReadDeviceIdentification = 0x0E
+
+// 读设备识别码类型
+const (
+	// ReadDeviceIDBasic 流式读取基本设备识别码(对象0x00~0x02)
+	ReadDeviceIDBasic = 1
+	// ReadDeviceIDRegular 流式读取常规设备识别码(对象0x00~0x7F)
+	ReadDeviceIDRegular = 2
+	// ReadDeviceIDExtended 流式读取扩展设备识别码(对象0x00~0xFF)
+	ReadDeviceIDExtended = 3
+	// ReadDeviceIDSpecific 读取单个对象
+	ReadDeviceIDSpecific = 4
+)
+
+// 设备识别码对象
+const (
+	// ObjectIDVendorName 厂商名称
+	ObjectIDVendorName = 0x00
+	// ObjectIDProductCode 产品代码
+	ObjectIDProductCode = 0x01
+	// ObjectIDMajorMinorRevision 主次版本号
+	ObjectIDMajorMinorRevision = 0x02
+	// ObjectIDVendorURL 厂商网址
+	ObjectIDVendorURL = 0x03
+	// ObjectIDProductName 产品名称
+	ObjectIDProductName = 0x04
+	// ObjectIDModelName 型号名称
+	ObjectIDModelName = 0x05
+	// ObjectIDUserApplicationName 用户应用名称
+	ObjectIDUserApplicationName = 0x06
+)
+
+// deviceIdentification 一次读设备识别码响应
+type deviceIdentification struct {
+	readCode        byte
+	conformityLevel byte
+	moreFollows     bool
+	nextObjectID    byte
+	objects         map[byte]string
+	// order 对象在响应中的顺序
+	order []byte
+}
+
+// parseDeviceIdentification 解析读设备识别码响应的协议数据单元数据
+func parseDeviceIdentification(mode ModbusMode, data []byte) (id *deviceIdentification, err error) {
+	if len(data) < 6 {
+		err = framingError(mode, "device identification response size '%v' less than minimum limit of '%v'", len(data), 6)
+		return
+	}
+	if data[0] != meiReadDeviceIdentification {
+		err = framingError(mode, "unexpected mei type '%X' in device identification response", data[0])
+		return
+	}
+	id = &deviceIdentification{
+		readCode:        data[1],
+		conformityLevel: data[2],
+		moreFollows:     data[3] == 0xFF,
+		nextObjectID:    data[4],
+		objects:         make(map[byte]string, data[5]),
+	}
+	count := int(data[5])
+	data = data[6:]
+	for i := 0; i < count; i++ {
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			err = framingError(mode, "device identification object '%v' of '%v' is truncated", i+1, count)
+			return
+		}
+		objectID, length := data[0], int(data[1])
+		id.objects[objectID] = string(data[2 : 2+length])
+		id.order = append(id.order, objectID)
+		data = data[2+length:]
+	}
+	if len(data) != 0 {
+		err = framingError(mode, "device identification response has '%v' unexpected trailing bytes", len(data))
+	}
+	return
+}
+
+// deviceIdentificationResponse 根据设备识别码对象生成响应数据,超过协议数据单元长度时分多次传输
+func deviceIdentificationResponse(objects map[byte]string, readCode, objectID byte) (results []byte, err error) {
+	var last byte
+	switch readCode {
+	case ReadDeviceIDBasic:
+		last = ObjectIDMajorMinorRevision
+	case ReadDeviceIDRegular:
+		last = 0x7F
+	case ReadDeviceIDExtended, ReadDeviceIDSpecific:
+		last = 0xFF
+	default:
+		return nil, ExceptionIllegalDataValue
+	}
+	ids := make([]int, 0, len(objects))
+	conformityLevel := byte(0x81)
+	for id := range objects {
+		ids = append(ids, int(id))
+		if id >= 0x80 {
+			conformityLevel = 0x83
+		} else if id > ObjectIDMajorMinorRevision && conformityLevel < 0x82 {
+			conformityLevel = 0x82
+		}
+	}
+	sort.Ints(ids)
+	results = []byte{meiReadDeviceIdentification, readCode, conformityLevel, 0x00, 0x00, 0x00}
+	if readCode == ReadDeviceIDSpecific {
+		value, exist := objects[objectID]
+		if !exist {
+			return nil, ExceptionIllegalDataAddress
+		}
+		results[5] = 1
+		return appendDeviceObject(results, objectID, value), nil
+	}
+	// 请求的起始对象不存在时从对象0开始
+	if _, exist := objects[objectID]; !exist || objectID > last {
+		objectID = 0
+	}
+	for _, id := range ids {
+		if id < int(objectID) || id > int(last) {
+			continue
+		}
+		value := objects[byte(id)]
+		if len(results)+2+deviceObjectLength(value) > pduMaxSize-1 && results[5] > 0 {
+			results[3], results[4] = 0xFF, byte(id)
+			break
+		}
+		results = appendDeviceObject(results, byte(id), value)
+		results[5]++
+	}
+	return
+}
+
+// deviceObjectLength 单个对象值的最大长度,响应头7字节、对象头2字节
+func deviceObjectLength(value string) int {
+	if limit := pduMaxSize - 7 - 2; len(value) > limit {
+		return limit
+	}
+	return len(value)
+}
+
+func appendDeviceObject(results []byte, objectID byte, value string) []byte {
+	length := deviceObjectLength(value)
+	results = append(results, objectID, byte(length))
+	return append(results, value[:length]...)
+}
+
+// deviceIdentificationDecoder 读设备识别码响应解析规则,按对象数量及各对象长度计算响应长度
+type deviceIdentificationDecoder struct{}
+
+func (d deviceIdentificationDecoder) ResponseLength(request ProtocolDataUnit, data []byte) (length int, ok bool) {
+	if len(data) < 6 {
+		return
+	}
+	length = 6
+	for i := 0; i < int(data[5]); i++ {
+		if len(data) < length+2 {
+			return 0, false
+		}
+		length += 2 + int(data[length+1])
+	}
+	return length, true
+}
+func (d deviceIdentificationDecoder) Decode(functionCode byte, data []byte) (pdu ProtocolDataUnit, err error) {
+	return NewProtocolDataUnit(functionCode, data), nil
+}
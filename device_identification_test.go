@@ -0,0 +1,83 @@
+package modbus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadDeviceIdentification(t *testing.T) {
+	h := NewStoreHandler(NewMemoryDataStore(0, 0, 0, 1))
+	h.Identification = map[byte]string{
+		ObjectIDVendorName:         "hi-way",
+		ObjectIDProductCode:        "GM-1",
+		ObjectIDMajorMinorRevision: "1.2",
+		ObjectIDProductName:        "meter",
+		0x80:                       strings.Repeat("a", 120),
+		0x81:                       strings.Repeat("b", 120),
+		0x82:                       strings.Repeat("c", 300),
+	}
+	_, address := startTestServer(t, h)
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+
+	objects, err := c.ReadDeviceIdentification(ReadDeviceIDBasic, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 3 || objects[ObjectIDVendorName] != "hi-way" || objects[ObjectIDMajorMinorRevision] != "1.2" {
+		t.Fatalf("unexpected basic objects %v", objects)
+	}
+	// 扩展对象超过单个响应长度,需多次读取
+	objects, err = c.ReadDeviceIdentification(ReadDeviceIDExtended, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 7 || objects[0x81] != h.Identification[0x81] || len(objects[0x82]) != pduMaxSize-9 {
+		t.Fatalf("unexpected extended objects %d", len(objects))
+	}
+	objects, err = c.ReadDeviceIdentification(ReadDeviceIDSpecific, ObjectIDProductName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[ObjectIDProductName] != "meter" {
+		t.Fatalf("unexpected specific objects %v", objects)
+	}
+	if _, err = c.ReadDeviceIdentification(ReadDeviceIDSpecific, ObjectIDModelName); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDeviceIdentificationResponse(t *testing.T) {
+	objects := map[byte]string{0: "a", 1: "b", 2: "c", 3: "d"}
+	results, err := deviceIdentificationResponse(objects, ReadDeviceIDRegular, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := parseDeviceIdentification(RTU, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.conformityLevel != 0x82 || id.moreFollows || len(id.order) != 2 || id.order[0] != 2 || id.order[1] != 3 {
+		t.Fatalf("unexpected response %+v", id)
+	}
+	// 起始对象不存在时从对象0开始
+	results, _ = deviceIdentificationResponse(objects, ReadDeviceIDBasic, 5)
+	if id, err = parseDeviceIdentification(RTU, results); err != nil || len(id.order) != 3 || id.order[0] != 0 {
+		t.Fatalf("unexpected response %+v %v", id, err)
+	}
+	if _, err = parseDeviceIdentification(RTU, append(results, 0)); err == nil {
+		t.Fatal("expected trailing bytes error")
+	}
+}
+
+func TestReadDeviceIdentificationUnsupported(t *testing.T) {
+	_, address := startTestServer(t, &testHandler{})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	if _, err := c.ReadDeviceIdentification(ReadDeviceIDBasic, 0); !errors.Is(err, ErrIllegalFunction) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
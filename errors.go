@@ -0,0 +1,189 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 异常响应错误,可配合 errors.Is 判断具体异常码
+var (
+	ErrIllegalFunction        error = ExceptionIllegalFunction
+	ErrIllegalDataAddress     error = ExceptionIllegalDataAddress
+	ErrIllegalDataValue       error = ExceptionIllegalDataValue
+	ErrServerDeviceFailure    error = ExceptionServerDeviceFailure
+	ErrAcknowledge            error = ExceptionAcknowledge
+	ErrServerDeviceBusy       error = ExceptionServerDeviceBusy
+	ErrMemoryParityError      error = ExceptionMemoryParityError
+	ErrGatewayPathUnavailable error = ExceptionGatewayPathUnavailable
+	ErrGatewayTargetFailed    error = ExceptionGatewayTargetFailed
+)
+
+// ExceptionError 从站返回的异常响应
+type ExceptionError struct {
+	// FunctionCode 请求功能码
+	FunctionCode byte
+	// ExceptionCode 异常码
+	ExceptionCode Exception
+}
+
+func (e *ExceptionError) Error() string {
+	text, exist := faults[byte(e.ExceptionCode)]
+	if !exist {
+		return fmt.Sprintf("modbus: error functionCode '%X' errorCode: '%X'", e.FunctionCode|0x80, byte(e.ExceptionCode))
+	}
+	return fmt.Sprintf("modbus: error functionCode '%X' errorCode: '%X' %s", e.FunctionCode|0x80, byte(e.ExceptionCode), text)
+}
+
+func (e *ExceptionError) Unwrap() error {
+	return e.ExceptionCode
+}
+
+// ChecksumError CRC/LRC校验失败
+type ChecksumError struct {
+	Mode ModbusMode
+	// Source 帧中携带的校验值
+	Source []byte
+	// Reality 根据帧数据计算的校验值
+	Reality []byte
+}
+
+func (e *ChecksumError) Error() string {
+	name := "crc"
+	if e.Mode == ASCII {
+		name = "lrc"
+	}
+	return fmt.Sprintf("modbus: %s validation failed source:'%v' reality:'%v' ", name, hex.EncodeToString(e.Source), hex.EncodeToString(e.Reality))
+}
+
+// TransactionError 响应事务标识与请求不一致
+type TransactionError struct {
+	Request  uint16
+	Response uint16
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("modbus: response transaction id '%v' does not match request '%v'", e.Response, e.Request)
+}
+
+// TimeoutError 等待响应超时
+type TimeoutError struct {
+	// Err 底层错误,可为nil
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return "modbus: timeout waiting for response"
+	}
+	return fmt.Sprintf("modbus: timeout waiting for response: %v", e.Err)
+}
+
+// Timeout 实现 net.Error 的超时判断
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// OfflineError 重连退避或冷却期间不尝试连接,直接返回此错误
+type OfflineError struct {
+	// State 当前连接状态
+	State ConnectionState
+	// Until 下一次允许连接的时间
+	Until time.Time
+	// Err 最近一次连接失败的原因
+	Err error
+}
+
+func (e *OfflineError) Error() string {
+	return fmt.Sprintf("modbus: connection %s until '%v': %v", e.State, e.Until.Format(time.RFC3339Nano), e.Err)
+}
+
+func (e *OfflineError) Unwrap() error {
+	return e.Err
+}
+
+// RetryError 重试后仍失败,Errors 依次为每次尝试的错误,等待重试期间ctx取消时最后一项为ctx错误
+// errors.Is、errors.As 依次匹配每次尝试的错误
+type RetryError struct {
+	Errors []error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("modbus: request failed after '%v' attempts: %v", len(e.Errors), e.Errors[len(e.Errors)-1])
+}
+
+func (e *RetryError) Unwrap() []error {
+	return e.Errors
+}
+
+func (e *RetryError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *RetryError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// FramingError 帧格式错误,如长度不符、起止符缺失、内容无法解析
+type FramingError struct {
+	Mode    ModbusMode
+	Message string
+	// Err 底层错误,可为nil
+	Err error
+}
+
+func (e *FramingError) Error() string {
+	if e.Err == nil {
+		return "modbus: " + e.Message
+	}
+	return fmt.Sprintf("modbus: %s: %v", e.Message, e.Err)
+}
+
+func (e *FramingError) Unwrap() error {
+	return e.Err
+}
+
+func framingError(mode ModbusMode, format string, a ...interface{}) error {
+	return &FramingError{Mode: mode, Message: fmt.Sprintf(format, a...)}
+}
+
+// verifyException 校验响应功能码,异常响应时返回 ExceptionError
+func verifyException(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
+	if aduRequest.GetFunctionCode() == aduResponse.GetFunctionCode() {
+		return
+	}
+	if aduResponse.GetFunctionCode() == aduRequest.GetFunctionCode()|0x80 {
+		data := aduResponse.GetPDU().GetData()
+		if len(data) == 0 {
+			return framingError(aduResponse.GetMode(), "exception response of functionCode '%X' has no errorCode", aduResponse.GetFunctionCode())
+		}
+		return &ExceptionError{FunctionCode: aduRequest.GetFunctionCode(), ExceptionCode: Exception(data[0])}
+	}
+	return fmt.Errorf("modbus: aduRequest  functionCode '%v' and aduResponse functionCode '%v' are inconsistent", aduRequest.GetFunctionCode(), aduResponse.GetFunctionCode())
+}
+
+// verifyEcho 校验响应数据是否与请求数据一致,用于原样返回请求的功能码
+func verifyEcho(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
+	requestData := aduRequest.GetPDU().GetData()
+	responseData := aduResponse.GetPDU().GetData()
+	if !bytes.Equal(requestData, responseData) {
+		err = fmt.Errorf("modbus: response data '%v' does not match request '%v'", hex.EncodeToString(responseData), hex.EncodeToString(requestData))
+	}
+	return
+}
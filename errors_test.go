@@ -0,0 +1,93 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExceptionError(t *testing.T) {
+	_, address := startTestServer(t, &testHandler{registers: make([]uint16, 4)})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewClient(NewTcpPackager(1), st)
+	_, _, err := c.ReadHoldingRegisters(2, 3)
+	if !errors.Is(err, ErrIllegalDataAddress) || errors.Is(err, ErrServerDeviceBusy) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var e *ExceptionError
+	if !errors.As(err, &e) || e.FunctionCode != FuncCodeReadHoldingRegisters || e.ExceptionCode != ExceptionIllegalDataAddress {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestAsciiExceptionError(t *testing.T) {
+	pk := NewAsciiPackager(1)
+	request, _ := pk.Encode(protocolDataUnit{functionCode: FuncCodeReadCoils, data: dataBlock(0, 1)})
+	response, err := pk.Decode(asciiFrame(1, FuncCodeReadCoils|0x80, []byte{byte(ExceptionServerDeviceBusy)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pk.Verify(request, response); !errors.Is(err, ErrServerDeviceBusy) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	pk := NewRtuPackager(1)
+	request, _ := pk.Encode(protocolDataUnit{functionCode: FuncCodeReadHoldingRegisters, data: dataBlock(0, 1)})
+	frame := rtuFrame(1, FuncCodeReadHoldingRegisters, []byte{2, 0, 1})
+	frame[3] ^= 0xFF
+	response, err := pk.Decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *ChecksumError
+	if err = pk.Verify(request, response); !errors.As(err, &e) || e.Mode != RTU {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestTransactionError(t *testing.T) {
+	pk := NewTcpPackager(1)
+	request, _ := pk.Encode(protocolDataUnit{functionCode: FuncCodeWriteSingleRegister, data: dataBlock(0, 1)})
+	frame := tcpFrame(100, 1, protocolDataUnit{functionCode: FuncCodeWriteSingleRegister, data: dataBlock(0, 1)})
+	response, err := pk.Decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *TransactionError
+	if err = pk.Verify(request, response); !errors.As(err, &e) || e.Response != 100 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var f *FramingError
+	if _, err = pk.Decode(frame[:len(frame)-1]); !errors.As(err, &f) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestMaskWriteRegisterEcho(t *testing.T) {
+	data := dataBlock(4, 0x00F2, 0x0025)
+	for _, pk := range []Packager{NewRtuPackager(1), NewAsciiPackager(1)} {
+		request, _ := pk.Encode(protocolDataUnit{functionCode: FuncCodeMaskWriteRegister, data: data})
+		response, err := pk.Decode(request.GetData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = pk.Verify(request, response); err != nil {
+			t.Fatal(err)
+		}
+		if err = verifyEcho(request, response); err != nil {
+			t.Fatalf("%s: %v", request.GetMode(), err)
+		}
+		frame := rtuFrame(1, FuncCodeMaskWriteRegister, dataBlock(4, 0x00F2, 0x0024))
+		if request.GetMode() == ASCII {
+			frame = asciiFrame(1, FuncCodeMaskWriteRegister, dataBlock(4, 0x00F2, 0x0024))
+		}
+		if response, err = pk.Decode(frame); err != nil {
+			t.Fatal(err)
+		}
+		if err = verifyEcho(request, response); err == nil {
+			t.Fatalf("%s: expected echo mismatch", request.GetMode())
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+const (
+	// fileRecordReferenceType 文件记录参考类型,固定为6
+	fileRecordReferenceType = 6
+	// fileRecordMaxNumber 最大记录号
+	fileRecordMaxNumber = 0x270F
+	// fileRecordRequestSize 读文件记录子请求长度
+	fileRecordRequestSize = 7
+	// fileRecordMaxDataLength 读文件记录请求及响应的最大字节数
+	fileRecordMaxDataLength = 0xF5
+)
+
+// FileRecord 文件记录,每条记录为一个16位寄存器
+type FileRecord struct {
+	// FileNumber 文件号
+	FileNumber uint16
+	// RecordNumber 起始记录号
+	RecordNumber uint16
+	// Length 读取的记录数量,写入时以 Data 长度为准
+	Length uint16
+	// Data 记录值
+	Data []uint16
+}
+
+// fileRecordChunk 拆分后的子请求
+type fileRecordChunk struct {
+	// index 所属 FileRecord 下标
+	index int
+	// offset 在所属 FileRecord 中的起始偏移
+	offset       int
+	fileNumber   uint16
+	recordNumber uint16
+	length       int
+}
+
+// planFileRecords 校验文件记录并拆分为子请求,按协议数据单元长度分组,每组为一次请求
+// 读取时子响应为长度、参考类型及记录值,请求及响应字节数不超过0xF5
+// 写入时子请求为参考类型、文件号、记录号、记录数量及记录值
+func planFileRecords(records []FileRecord, write bool) (groups [][]fileRecordChunk, err error) {
+	overhead := 2
+	// 功能码与字节数之后的数据不超过limit
+	limit := fileRecordMaxDataLength + 2
+	if write {
+		overhead = fileRecordRequestSize
+		limit = pduMaxSize
+	}
+	var group []fileRecordChunk
+	// 功能码与字节数
+	used, requestUsed := 2, 2
+	for i, record := range records {
+		length := int(record.Length)
+		if write {
+			length = len(record.Data)
+		}
+		if record.FileNumber == 0 {
+			return nil, fmt.Errorf("modbus: file number of record '%v' must be between '%v' and '%v'", i, 1, 65535)
+		}
+		if length < 1 || int(record.RecordNumber)+length-1 > fileRecordMaxNumber {
+			return nil, fmt.Errorf("modbus: records '%v'~'%v' of file '%v' must be between '%v' and '%v'", record.RecordNumber, int(record.RecordNumber)+length-1, record.FileNumber, 0, fileRecordMaxNumber)
+		}
+		for offset := 0; offset < length; {
+			n := (limit - used - overhead) / 2
+			if n < 1 || requestUsed+fileRecordRequestSize > limit {
+				groups = append(groups, group)
+				group, used, requestUsed = nil, 2, 2
+				continue
+			}
+			if n > length-offset {
+				n = length - offset
+			}
+			group = append(group, fileRecordChunk{
+				index:        i,
+				offset:       offset,
+				fileNumber:   record.FileNumber,
+				recordNumber: record.RecordNumber + uint16(offset),
+				length:       n,
+			})
+			used += overhead + n*2
+			requestUsed += fileRecordRequestSize
+			offset += n
+		}
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return
+}
+
+func (c *ModbusClient) ReadFileRecord(records []FileRecord) (results []FileRecord, err error) {
+	groups, err := planFileRecords(records, false)
+	if err != nil {
+		return
+	}
+	results = make([]FileRecord, len(records))
+	for i, record := range records {
+		results[i] = record
+		results[i].Data = make([]uint16, record.Length)
+	}
+	for _, group := range groups {
+		data := []byte{byte(len(group) * fileRecordRequestSize)}
+		for _, chunk := range group {
+			data = append(data, fileRecordReferenceType)
+			data = append(data, dataBlock(chunk.fileNumber, chunk.recordNumber, uint16(chunk.length))...)
+		}
+		pdu := protocolDataUnit{
+			functionCode: FuncCodeReadFileRecord,
+			data:         data,
+			length:       len(data),
+		}
+		var response ApplicationDataUnit
+		if _, response, err = c.invoke(pdu); err != nil {
+			return nil, err
+		}
+		if err = parseFileRecordResponse(response, group, results); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// parseFileRecordResponse 解析读文件记录响应并写入results
+func parseFileRecordResponse(response ApplicationDataUnit, group []fileRecordChunk, results []FileRecord) (err error) {
+	pdu := response.GetPDU()
+	data := pdu.GetData()
+	if pdu.Length() != len(data) {
+		return framingError(response.GetMode(), "response byte count '%v' does not match data length '%v'", pdu.Length(), len(data))
+	}
+	for _, chunk := range group {
+		size := 1 + chunk.length*2
+		if len(data) < 1+size || int(data[0]) != size {
+			return framingError(response.GetMode(), "file '%v' record '%v' response length does not match '%v' records", chunk.fileNumber, chunk.recordNumber, chunk.length)
+		}
+		if data[1] != fileRecordReferenceType {
+			return framingError(response.GetMode(), "file record reference type '%v' must be '%v'", data[1], fileRecordReferenceType)
+		}
+		copy(results[chunk.index].Data[chunk.offset:], registers(data[2:1+size]))
+		data = data[1+size:]
+	}
+	if len(data) != 0 {
+		return framingError(response.GetMode(), "file record response has '%v' unexpected trailing bytes", len(data))
+	}
+	return
+}
+
+func (c *ModbusClient) WriteFileRecord(records []FileRecord) (err error) {
+	groups, err := planFileRecords(records, true)
+	if err != nil {
+		return
+	}
+	for _, group := range groups {
+		data := []byte{0}
+		for _, chunk := range group {
+			value := records[chunk.index].Data[chunk.offset : chunk.offset+chunk.length]
+			data = append(data, fileRecordReferenceType)
+			data = append(data, dataBlock(chunk.fileNumber, chunk.recordNumber, uint16(chunk.length))...)
+			data = append(data, dataBlock(value...)...)
+		}
+		data[0] = byte(len(data) - 1)
+		pdu := protocolDataUnit{
+			functionCode: FuncCodeWriteFileRecord,
+			data:         data,
+			length:       len(data),
+		}
+		var request, response ApplicationDataUnit
+		if request, response, err = c.invoke(pdu); err != nil {
+			return
+		}
+		if err = verifyEcho(request, response); err != nil {
+			return
+		}
+	}
+	return
+}
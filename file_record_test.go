@@ -0,0 +1,160 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// fileRecordSlave 在测试串口上模拟支持文件记录的从站,返回每次请求的协议数据单元长度
+func fileRecordSlave(t *testing.T, port *testPort, files map[uint16][]uint16) <-chan int {
+	sizes := make(chan int, 64)
+	go func() {
+		for frame := range port.written {
+			_, request, err := NewRtuPackager(1).DecodeRequest(frame)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data := request.Data
+			sizes <- 1 + len(data)
+			response := []byte{0}
+			switch request.FunctionCode {
+			case FuncCodeReadFileRecord:
+				for sub := data[1:]; len(sub) >= 7; sub = sub[7:] {
+					file, record, length := binary.BigEndian.Uint16(sub[1:]), binary.BigEndian.Uint16(sub[3:]), binary.BigEndian.Uint16(sub[5:])
+					response = append(response, byte(1+length*2), fileRecordReferenceType)
+					response = append(response, dataBlock(files[file][record:record+length]...)...)
+				}
+				response[0] = byte(len(response) - 1)
+			case FuncCodeWriteFileRecord:
+				for sub := data[1:]; len(sub) >= 7; {
+					file, record, length := binary.BigEndian.Uint16(sub[1:]), binary.BigEndian.Uint16(sub[3:]), binary.BigEndian.Uint16(sub[5:])
+					copy(files[file][record:], registers(sub[7:7+length*2]))
+					sub = sub[7+length*2:]
+				}
+				response = data
+			}
+			port.reads <- rtuFrame(1, request.FunctionCode, response)
+		}
+	}()
+	return sizes
+}
+
+func TestFileRecord(t *testing.T) {
+	port := newTestPort()
+	port.timeout = 20 * time.Millisecond
+	st := &SerialPortTransporter{port: port}
+	st.BaudRate = 115200
+	c := NewModbusClient(NewRtuPackager(1), st)
+	files := map[uint16][]uint16{3: make([]uint16, 10000), 4: make([]uint16, 10000)}
+	sizes := fileRecordSlave(t, port, files)
+	defer close(port.written)
+
+	value := make([]uint16, 300)
+	for i := range value {
+		value[i] = uint16(i + 1)
+	}
+	if err := c.WriteFileRecord([]FileRecord{
+		{FileNumber: 4, RecordNumber: 1, Data: []uint16{0x06AF, 0x04BE}},
+		{FileNumber: 3, RecordNumber: 9, Data: value},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if files[4][1] != 0x06AF || files[4][2] != 0x04BE || files[3][9] != 1 || files[3][308] != 300 {
+		t.Fatal("unexpected file content after write")
+	}
+	results, err := c.ReadFileRecord([]FileRecord{
+		{FileNumber: 4, RecordNumber: 1, Length: 2},
+		{FileNumber: 3, RecordNumber: 9, Length: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Data[0] != 0x06AF || results[0].Data[1] != 0x04BE {
+		t.Fatalf("unexpected records %v", results)
+	}
+	for i, v := range results[1].Data {
+		if v != value[i] {
+			t.Fatalf("unexpected record %d value %d", i, v)
+		}
+	}
+	// 写入及读取均需拆分为多次请求,每次不超过协议数据单元最大长度
+	count := 0
+	for len(sizes) > 0 {
+		if size := <-sizes; size > pduMaxSize {
+			t.Fatalf("request pdu size %d exceeds %d", size, pduMaxSize)
+		}
+		count++
+	}
+	if count != 6 {
+		t.Fatalf("unexpected request count %d", count)
+	}
+}
+
+func TestFileRecordRange(t *testing.T) {
+	c := NewModbusClient(NewRtuPackager(1), &SerialPortTransporter{port: newTestPort()})
+	if _, err := c.ReadFileRecord([]FileRecord{{FileNumber: 0, Length: 1}}); err == nil {
+		t.Fatal("expected file number error")
+	}
+	if err := c.WriteFileRecord([]FileRecord{{FileNumber: 1, RecordNumber: 9999, Data: []uint16{1, 2}}}); err == nil {
+		t.Fatal("expected record number error")
+	}
+}
+
+func TestPlanFileRecords(t *testing.T) {
+	records := make([]FileRecord, 40)
+	for i := range records {
+		records[i] = FileRecord{FileNumber: 1, RecordNumber: uint16(i), Length: 1}
+	}
+	// 读请求每个子请求7字节,单次最多35个
+	groups, err := planFileRecords(records, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || len(groups[0]) != 35 || len(groups[1]) != 5 {
+		t.Fatalf("unexpected groups %d", len(groups))
+	}
+}
+
+// 读文件记录响应字节数不超过0xF5,写文件记录请求数据不超过协议数据单元长度
+func TestPlanFileRecordsByteCountLimit(t *testing.T) {
+	records := []FileRecord{
+		{FileNumber: 1, RecordNumber: 0, Length: 500},
+		{FileNumber: 2, RecordNumber: 0, Length: 3},
+		{FileNumber: 3, RecordNumber: 100, Length: 121},
+	}
+	groups, err := planFileRecords(records, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, group := range groups {
+		response := 0
+		for _, chunk := range group {
+			response += 2 + chunk.length*2
+			total += chunk.length
+		}
+		if response > 0xF5 || len(group)*fileRecordRequestSize > 0xF5 {
+			t.Fatalf("response byte count '%v' exceeds 0xF5", response)
+		}
+	}
+	if total != 624 {
+		t.Fatalf("unexpected planned records '%v'", total)
+	}
+	for i := range records {
+		records[i].Data = make([]uint16, records[i].Length)
+	}
+	if groups, err = planFileRecords(records, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range groups {
+		request := 0
+		for _, chunk := range group {
+			request += fileRecordRequestSize + chunk.length*2
+		}
+		if request > 0xFB {
+			t.Fatalf("request byte count '%v' exceeds 0xFB", request)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 结构体标签格式: `modbus:"数据表,地址[,数据类型][,字节序][,scale=系数]"`
+// 数据表: coil、discrete、input、holding
+// 数据类型: bool、uint16、int16、uint32、int32、float32、uint64、int64、float64,省略时按字段类型推断
+// 字节序: abcd、cdab、badc、dcba,默认abcd
+// scale: 读取时 字段值=原始值*系数,写入时 原始值=字段值/系数
+// 例: Voltage float32 `modbus:"holding,100,float32,cdab,scale=0.1"`
+const mappingTag = "modbus"
+
+const (
+	tableCoil     = "coil"
+	tableDiscrete = "discrete"
+	tableInput    = "input"
+	tableHolding  = "holding"
+)
+
+// 每个数据类型占用的寄存器数量
+var dataTypeSize = map[string]uint16{
+	"uint16":  1,
+	"int16":   1,
+	"uint32":  2,
+	"int32":   2,
+	"float32": 2,
+	"uint64":  4,
+	"int64":   4,
+	"float64": 4,
+}
+
+type fieldMapping struct {
+	index    int
+	name     string
+	table    string
+	address  uint16
+	quantity uint16
+	dataType string
+	order    ByteOrder
+	scale    float64
+}
+
+func (m *fieldMapping) isBit() bool {
+	return m.table == tableCoil || m.table == tableDiscrete
+}
+
+var mappingCache sync.Map
+
+func structMappings(t reflect.Type) (mappings []*fieldMapping, err error) {
+	if cached, ok := mappingCache.Load(t); ok {
+		return cached.([]*fieldMapping), nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup(mappingTag)
+		if !ok || tag == "-" {
+			continue
+		}
+		if field.PkgPath != "" {
+			return nil, fmt.Errorf("modbus: field '%v' with modbus tag must be exported", field.Name)
+		}
+		m, err := parseMapping(field, tag)
+		if err != nil {
+			return nil, err
+		}
+		m.index = i
+		mappings = append(mappings, m)
+	}
+	mappingCache.Store(t, mappings)
+	return
+}
+
+func parseMapping(field reflect.StructField, tag string) (m *fieldMapping, err error) {
+	parts := strings.Split(tag, ",")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("modbus: field '%v' tag '%v' must contain table and address", field.Name, tag)
+	}
+	m = &fieldMapping{name: field.Name, table: strings.TrimSpace(parts[0])}
+	switch m.table {
+	case tableCoil, tableDiscrete, tableInput, tableHolding:
+	default:
+		return nil, fmt.Errorf("modbus: field '%v' has unknown table '%v'", field.Name, m.table)
+	}
+	address, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 0, 16)
+	if err != nil {
+		return nil, fmt.Errorf("modbus: field '%v' has invalid address '%v'", field.Name, parts[1])
+	}
+	m.address = uint16(address)
+	for _, part := range parts[2:] {
+		part = strings.ToLower(strings.TrimSpace(part))
+		switch {
+		case part == "bool":
+			m.dataType = part
+		case dataTypeSize[part] > 0:
+			m.dataType = part
+		case strings.HasPrefix(part, "scale="):
+			m.scale, err = strconv.ParseFloat(strings.TrimPrefix(part, "scale="), 64)
+			if err != nil || m.scale == 0 {
+				return nil, fmt.Errorf("modbus: field '%v' has invalid scale '%v'", field.Name, part)
+			}
+		default:
+			if m.order, err = ParseByteOrder(part); err != nil {
+				return nil, fmt.Errorf("modbus: field '%v' has unknown option '%v'", field.Name, part)
+			}
+		}
+	}
+	if m.isBit() {
+		if m.dataType != "" && m.dataType != "bool" || field.Type.Kind() != reflect.Bool {
+			return nil, fmt.Errorf("modbus: field '%v' in table '%v' must be bool", field.Name, m.table)
+		}
+		m.dataType, m.quantity = "bool", 1
+		return
+	}
+	if m.dataType == "" {
+		m.dataType = field.Type.Kind().String()
+	}
+	m.quantity = dataTypeSize[m.dataType]
+	if m.quantity == 0 {
+		return nil, fmt.Errorf("modbus: field '%v' in table '%v' needs a register data type", field.Name, m.table)
+	}
+	switch field.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return nil, fmt.Errorf("modbus: field '%v' of kind '%v' can not hold '%v'", field.Name, field.Type.Kind(), m.dataType)
+	}
+	return
+}
+
+// decode 将寄存器值解析为int64、uint64或float64
+func (m *fieldMapping) decode(value []uint16) interface{} {
+	data := m.order.bytes(value)
+	switch m.dataType {
+	case "uint16":
+		return uint64(binary.BigEndian.Uint16(data))
+	case "int16":
+		return int64(int16(binary.BigEndian.Uint16(data)))
+	case "uint32":
+		return uint64(binary.BigEndian.Uint32(data))
+	case "int32":
+		return int64(int32(binary.BigEndian.Uint32(data)))
+	case "float32":
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case "uint64":
+		return binary.BigEndian.Uint64(data)
+	case "int64":
+		return int64(binary.BigEndian.Uint64(data))
+	default:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+}
+
+// encode 将字段值编码为寄存器值
+func (m *fieldMapping) encode(field reflect.Value) []uint16 {
+	var i int64
+	var u uint64
+	var f float64
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i = field.Int()
+		u, f = uint64(i), float64(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u = field.Uint()
+		i, f = int64(u), float64(u)
+	default:
+		f = field.Float()
+		i, u = roundFloat(f)
+	}
+	if m.scale != 0 {
+		f /= m.scale
+		i, u = roundFloat(f)
+	}
+	data := make([]byte, 2*m.quantity)
+	switch m.dataType {
+	case "uint16":
+		binary.BigEndian.PutUint16(data, uint16(u))
+	case "int16":
+		binary.BigEndian.PutUint16(data, uint16(i))
+	case "uint32":
+		binary.BigEndian.PutUint32(data, uint32(u))
+	case "int32":
+		binary.BigEndian.PutUint32(data, uint32(i))
+	case "float32":
+		binary.BigEndian.PutUint32(data, math.Float32bits(float32(f)))
+	case "uint64":
+		binary.BigEndian.PutUint64(data, u)
+	case "int64":
+		binary.BigEndian.PutUint64(data, uint64(i))
+	default:
+		binary.BigEndian.PutUint64(data, math.Float64bits(f))
+	}
+	return m.order.registers(data)
+}
+
+// roundFloat 将浮点数四舍五入为整数,负数转换为无符号数时按补码处理
+// 负浮点数直接转换为uint64的结果依赖平台,如arm64上为0
+func roundFloat(f float64) (i int64, u uint64) {
+	r := math.Round(f)
+	i = int64(r)
+	if r >= 0 {
+		u = uint64(r)
+	} else {
+		u = uint64(i)
+	}
+	return
+}
+
+// set 将解析后的原始值按系数写入字段
+func (m *fieldMapping) set(field reflect.Value, raw interface{}) error {
+	var f float64
+	switch v := raw.(type) {
+	case int64:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float64:
+		f = v
+	}
+	if m.scale != 0 {
+		f *= m.scale
+		raw = f
+	}
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		field.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch v := raw.(type) {
+		case int64:
+			i = v
+		case uint64:
+			i = int64(v)
+		default:
+			i = int64(math.Round(f))
+		}
+		if field.OverflowInt(i) {
+			return fmt.Errorf("modbus: value '%v' overflows field '%v'", raw, m.name)
+		}
+		field.SetInt(i)
+	default:
+		var u uint64
+		switch v := raw.(type) {
+		case int64:
+			u = uint64(v)
+		case uint64:
+			u = v
+		default:
+			u = uint64(math.Round(f))
+		}
+		if field.OverflowUint(u) {
+			return fmt.Errorf("modbus: value '%v' overflows field '%v'", raw, m.name)
+		}
+		field.SetUint(u)
+	}
+	return nil
+}
+
+// mappingRange 合并后的一次读写范围
+type mappingRange struct {
+	table    string
+	address  uint16
+	quantity uint16
+	mappings []*fieldMapping
+}
+
+// mergeMappings 按数据表将地址相邻或重叠的字段合并为尽量少的读写范围
+func mergeMappings(mappings []*fieldMapping, write bool) (ranges []*mappingRange) {
+	sorted := make([]*fieldMapping, len(mappings))
+	copy(sorted, mappings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].table != sorted[j].table {
+			return sorted[i].table < sorted[j].table
+		}
+		return sorted[i].address < sorted[j].address
+	})
+	var current *mappingRange
+	for _, m := range sorted {
+		limit := mappingLimit(m.table, write)
+		if current != nil && current.table == m.table && int(m.address) <= int(current.address)+int(current.quantity) {
+			end := int(m.address) + int(m.quantity)
+			if end-int(current.address) <= limit {
+				if end > int(current.address)+int(current.quantity) {
+					current.quantity = uint16(end - int(current.address))
+				}
+				current.mappings = append(current.mappings, m)
+				continue
+			}
+		}
+		current = &mappingRange{table: m.table, address: m.address, quantity: m.quantity, mappings: []*fieldMapping{m}}
+		ranges = append(ranges, current)
+	}
+	return
+}
+
+func mappingLimit(table string, write bool) int {
+	switch {
+	case table == tableCoil && write:
+		return 1968
+	case table == tableCoil || table == tableDiscrete:
+		return 2000
+	case write:
+		return 123
+	}
+	return 125
+}
+
+// ReadStruct 按结构体字段的modbus标签读取数据,v必须为结构体指针
+// 同一数据表中地址相邻或重叠的字段合并为一次请求
+func ReadStruct(c Client, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("modbus: ReadStruct requires a pointer to struct, got '%T'", v)
+	}
+	rv = rv.Elem()
+	mappings, err := structMappings(rv.Type())
+	if err != nil {
+		return
+	}
+	for _, r := range mergeMappings(mappings, false) {
+		if r.table == tableCoil || r.table == tableDiscrete {
+			var results ApplicationDataUnit
+			if r.table == tableCoil {
+				_, results, err = c.ReadCoils(r.address, r.quantity)
+			} else {
+				_, results, err = c.ReadDiscreteInputs(r.address, r.quantity)
+			}
+			if err != nil {
+				return
+			}
+			var bits []bool
+			if bits, err = BitValues(results, r.quantity); err != nil {
+				return
+			}
+			for _, m := range r.mappings {
+				rv.Field(m.index).SetBool(bits[m.address-r.address])
+			}
+			continue
+		}
+		var results ApplicationDataUnit
+		if r.table == tableHolding {
+			_, results, err = c.ReadHoldingRegisters(r.address, r.quantity)
+		} else {
+			_, results, err = c.ReadInputRegisters(r.address, r.quantity)
+		}
+		if err != nil {
+			return
+		}
+		var value []uint16
+		if value, err = RegisterValues(results, r.quantity); err != nil {
+			return
+		}
+		for _, m := range r.mappings {
+			offset := m.address - r.address
+			if err = m.set(rv.Field(m.index), m.decode(value[offset:offset+m.quantity])); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// WriteStruct 按结构体字段的modbus标签写入数据,fields为需要写入的字段名,为空时写入所有线圈及保持寄存器字段
+// 地址相邻的字段合并为一次写多个线圈/写多个寄存器请求
+func WriteStruct(c Client, v interface{}, fields ...string) (err error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("modbus: WriteStruct requires a struct or pointer to struct, got '%T'", v)
+	}
+	mappings, err := structMappings(rv.Type())
+	if err != nil {
+		return
+	}
+	var selected []*fieldMapping
+	if len(fields) == 0 {
+		for _, m := range mappings {
+			if m.table == tableCoil || m.table == tableHolding {
+				selected = append(selected, m)
+			}
+		}
+	}
+	for _, name := range fields {
+		var found *fieldMapping
+		for _, m := range mappings {
+			if m.name == name {
+				found = m
+				break
+			}
+		}
+		if found == nil {
+			return fmt.Errorf("modbus: field '%v' has no modbus tag", name)
+		}
+		if found.table != tableCoil && found.table != tableHolding {
+			return fmt.Errorf("modbus: field '%v' in table '%v' is read only", name, found.table)
+		}
+		selected = append(selected, found)
+	}
+	for _, r := range mergeMappings(selected, true) {
+		if r.table == tableCoil {
+			bits := make([]bool, r.quantity)
+			for _, m := range r.mappings {
+				bits[m.address-r.address] = rv.Field(m.index).Bool()
+			}
+			if _, _, err = c.WriteMultipleCoils(r.address, r.quantity, bits); err != nil {
+				return
+			}
+			continue
+		}
+		value := make([]uint16, r.quantity)
+		for _, m := range r.mappings {
+			copy(value[m.address-r.address:], m.encode(rv.Field(m.index)))
+		}
+		if _, _, err = c.WriteMultipleRegisters(r.address, r.quantity, dataBlock(value...)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package modbus
+
+import (
+	"testing"
+)
+
+type testMeter struct {
+	Voltage float32 `modbus:"holding,100,float32,cdab,scale=0.1"`
+	Current float64 `modbus:"holding,102,uint16,scale=0.01"`
+	Energy  uint64  `modbus:"holding,103,uint64"`
+	Status  int16   `modbus:"input,0"`
+	Alarm   bool    `modbus:"coil,5"`
+	Remote  bool    `modbus:"discrete,1"`
+	Name    string
+}
+
+// countingClient 统计请求次数
+type countingClient struct {
+	Client
+	requests int
+}
+
+func (c *countingClient) ReadHoldingRegisters(address, quantity uint16) (request ApplicationDataUnit, results ApplicationDataUnit, err error) {
+	c.requests++
+	return c.Client.ReadHoldingRegisters(address, quantity)
+}
+
+func TestReadWriteStruct(t *testing.T) {
+	store := NewMemoryDataStore(16, 16, 16, 200)
+	_ = store.WriteInputRegisters(0, []uint16{0xFFFE})
+	_ = store.WriteDiscreteInputs(1, []bool{true})
+	_, address := startTestServer(t, NewStoreHandler(store))
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := &countingClient{Client: NewClient(NewTcpPackager(1), st)}
+	meter := testMeter{Voltage: 230.1, Current: 1.25, Energy: 1 << 40, Alarm: true}
+	if err := WriteStruct(c, meter); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := store.ReadHoldingRegisters(100, 3)
+	if CDAB.Float32(raw) != 2301 || raw[2] != 125 {
+		t.Fatalf("unexpected registers %v", raw)
+	}
+	var read testMeter
+	if err := ReadStruct(c, &read); err != nil {
+		t.Fatal(err)
+	}
+	if c.requests != 1 {
+		t.Fatalf("holding registers should be read in one request, got %d", c.requests)
+	}
+	if read.Voltage != 230.1 || read.Current != 1.25 || read.Energy != 1<<40 || read.Status != -2 || !read.Alarm || !read.Remote {
+		t.Fatalf("unexpected struct %+v", read)
+	}
+	read.Current = 2.5
+	if err := WriteStruct(c, &read, "Current"); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ = store.ReadHoldingRegisters(102, 1); raw[0] != 250 {
+		t.Fatalf("unexpected registers %v", raw)
+	}
+	if err := WriteStruct(c, &read, "Status"); err == nil {
+		t.Fatal("input registers should be read only")
+	}
+}
+
+type testSignedMeter struct {
+	Power       float64 `modbus:"holding,0,int16,scale=0.1"`
+	Temperature float32 `modbus:"holding,1,int32"`
+	Offset      int16   `modbus:"holding,3,int16"`
+}
+
+// 负数按补码写入,不依赖负浮点数到无符号整数的转换
+func TestWriteStructNegativeScaled(t *testing.T) {
+	if i, u := roundFloat(-50.4); i != -50 || u != 0xFFFFFFFFFFFFFFCE {
+		t.Fatalf("unexpected rounding %v %X", i, u)
+	}
+	store := NewMemoryDataStore(0, 0, 0, 16)
+	_, address := startTestServer(t, NewStoreHandler(store))
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewClient(NewTcpPackager(1), st)
+	meter := testSignedMeter{Power: -5.0, Temperature: -12, Offset: -3}
+	if err := WriteStruct(c, meter); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := store.ReadHoldingRegisters(0, 4)
+	if raw[0] != 0xFFCE || raw[1] != 0xFFFF || raw[2] != 0xFFF4 || raw[3] != 0xFFFD {
+		t.Fatalf("unexpected registers %04X", raw)
+	}
+	var read testSignedMeter
+	if err := ReadStruct(c, &read); err != nil {
+		t.Fatal(err)
+	}
+	if read != meter {
+		t.Fatalf("unexpected struct %+v, want %+v", read, meter)
+	}
+}
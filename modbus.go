@@ -1,7 +1,11 @@
 package modbus
 
 import (
+	"context"
 	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
 )
 
 const (
@@ -26,9 +30,36 @@ const (
 	FuncCodeWriteSingleRegister = 6
 	// FuncCodeWriteMultipleRegisters 功能码:写多个寄存器
 	FuncCodeWriteMultipleRegisters = 16
+	// FuncCodeMaskWriteRegister 功能码:屏蔽写寄存器
+	FuncCodeMaskWriteRegister = 22
+	// FuncCodeReadFileRecord 功能码:读文件记录
+	FuncCodeReadFileRecord = 20
+	// FuncCodeWriteFileRecord 功能码:写文件记录
+	FuncCodeWriteFileRecord = 21
+	// FuncCodeReadFIFOQueue 功能码:读FIFO队列
+	FuncCodeReadFIFOQueue = 24
+	// FuncCodeEncapsulatedInterface 功能码:封装接口传输,用于读设备识别码
+	FuncCodeEncapsulatedInterface = 43
 	// FuncCodeReadWriteMultipleRegisters 功能码:读/写多个寄存器
 	FuncCodeReadWriteMultipleRegisters = 23
+
+	//串行链路诊断
+
+	// FuncCodeReadExceptionStatus 功能码:读异常状态
+	FuncCodeReadExceptionStatus = 7
+	// FuncCodeDiagnostics 功能码:诊断
+	FuncCodeDiagnostics = 8
+	// FuncCodeGetCommEventCounter 功能码:获取通信事件计数器
+	FuncCodeGetCommEventCounter = 11
+	// FuncCodeGetCommEventLog 功能码:获取通信事件记录
+	FuncCodeGetCommEventLog = 12
+	// FuncCodeReportServerID 功能码:报告从站ID
+	FuncCodeReportServerID = 17
 )
+
+// pduMaxSize 协议数据单元最大长度
+const pduMaxSize = 253
+
 const (
 	TCP   = ModbusMode("TCP")
 	RTU   = ModbusMode("RTU")
@@ -51,6 +82,38 @@ var (
 
 type ModbusMode string
 
+// Exception 异常码
+type Exception byte
+
+const (
+	// ExceptionIllegalFunction 异常码:非法功能码
+	ExceptionIllegalFunction Exception = 1
+	// ExceptionIllegalDataAddress 异常码:非法数据地址
+	ExceptionIllegalDataAddress Exception = 2
+	// ExceptionIllegalDataValue 异常码:非法数据值
+	ExceptionIllegalDataValue Exception = 3
+	// ExceptionServerDeviceFailure 异常码:从站设备故障
+	ExceptionServerDeviceFailure Exception = 4
+	// ExceptionAcknowledge 异常码:确认
+	ExceptionAcknowledge Exception = 5
+	// ExceptionServerDeviceBusy 异常码:从站设备忙
+	ExceptionServerDeviceBusy Exception = 6
+	// ExceptionMemoryParityError 异常码:存储奇偶性差错
+	ExceptionMemoryParityError Exception = 8
+	// ExceptionGatewayPathUnavailable 异常码:网关路径不可用
+	ExceptionGatewayPathUnavailable Exception = 10
+	// ExceptionGatewayTargetFailed 异常码:网关目标设备响应失败
+	ExceptionGatewayTargetFailed Exception = 11
+)
+
+func (e Exception) Error() string {
+	text, exist := faults[byte(e)]
+	if !exist {
+		return fmt.Sprintf("modbus: exception '%X'", byte(e))
+	}
+	return fmt.Sprintf("modbus: exception %s", text)
+}
+
 // ProtocolDataUnit 协议数据单元
 type ProtocolDataUnit interface {
 	GetFunctionCode() (f byte)
@@ -80,6 +143,15 @@ func (pdu protocolDataUnit) ToHex() (h string) {
 	return hex.EncodeToString(pdu.data)
 }
 
+// NewProtocolDataUnit 创建协议数据单元,data为功能码之后的数据
+func NewProtocolDataUnit(functionCode byte, data []byte) ProtocolDataUnit {
+	return protocolDataUnit{
+		functionCode: functionCode,
+		data:         data,
+		length:       len(data),
+	}
+}
+
 // ApplicationDataUnit 应用数据单元
 type ApplicationDataUnit interface {
 	GetSlaveId() byte
@@ -135,9 +207,14 @@ func (u applicationDataUnit) GetMode() ModbusMode {
 
 // Packager 包解析器
 type Packager interface {
-	Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, err error)
+	Encode(pdu ProtocolDataUnit) (adu ApplicationDataUnit, err error)
 	Decode(results []byte) (adu ApplicationDataUnit, err error)
+	// DecodeRequest 解析请求帧,帧格式错误时request为nil
+	// 帧格式正确但请求内容非法时同时返回request与 Exception 类型的错误
+	DecodeRequest(results []byte) (adu ApplicationDataUnit, request *Request, err error)
 	Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error)
+	// ForUnit 返回按指定从站ID编码的包解析器,TCP包解析器与原解析器共享事务计数
+	ForUnit(slaveID byte) Packager
 }
 
 // Transporter 数据传输器
@@ -147,3 +224,111 @@ type Transporter interface {
 	Send(aduRequest ApplicationDataUnit) (aduResponse []byte, err error)
 	Close() error
 }
+
+// ContextTransporter 支持context的数据传输器,context取消或超时时中止等待及读写
+type ContextTransporter interface {
+	Transporter
+	SendContext(ctx context.Context, aduRequest ApplicationDataUnit) (aduResponse []byte, err error)
+}
+
+// BroadcastTransporter 支持广播的数据传输器,发送后不等待响应
+// 下一次请求前需等待广播转换延时,使从站有时间处理广播请求
+type BroadcastTransporter interface {
+	Transporter
+	Broadcast(ctx context.Context, aduRequest ApplicationDataUnit) (err error)
+}
+
+// transportLock 可被context取消等待的互斥锁,零值可用
+type transportLock struct {
+	once sync.Once
+	sem  chan struct{}
+}
+
+func (l *transportLock) Lock(ctx context.Context) error {
+	l.once.Do(func() { l.sem = make(chan struct{}, 1) })
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *transportLock) Unlock() {
+	<-l.sem
+}
+
+// watchContext 在context取消时调用cancel,返回的stop用于结束监听,stop返回后cancel不会再被调用
+func watchContext(ctx context.Context, cancel func()) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// contextDeadline 返回超时时间与ctx截止时间中较早者
+func contextDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// contextErr 返回ctx的错误,截止时间已到而ctx尚未标记超时时返回 context.DeadlineExceeded
+func contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// defaultTurnaroundDelay 广播转换延时默认值
+const defaultTurnaroundDelay = 100 * time.Millisecond
+
+// turnaroundDelay 返回广播转换延时,未设置时使用默认值
+func turnaroundDelay(delay time.Duration) time.Duration {
+	if delay > 0 {
+		return delay
+	}
+	return defaultTurnaroundDelay
+}
+
+// waitTurnaround 等待至广播转换延时结束
+func waitTurnaround(ctx context.Context, idle time.Time) error {
+	if idle.IsZero() {
+		return nil
+	}
+	return sleepContext(ctx, time.Until(idle))
+}
+
+// sleepContext 等待指定时间,ctx取消时提前返回ctx错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,171 @@
+package modbus
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReconnectInitialDelay = 100 * time.Millisecond
+	defaultReconnectMaxDelay     = 30 * time.Second
+	defaultReconnectMultiplier   = 2
+)
+
+// ConnectionState 连接状态
+type ConnectionState int
+
+const (
+	// StateDisconnected 未连接,下次发送时连接
+	StateDisconnected ConnectionState = iota
+	// StateConnected 已连接
+	StateConnected
+	// StateReconnecting 连接失败,退避等待后重连
+	StateReconnecting
+	// StateOffline 连续失败次数达到上限,冷却期内不再尝试连接
+	StateOffline
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateOffline:
+		return "offline"
+	}
+	return "unknown"
+}
+
+// ReconnectPolicy 重连策略,连接失败后按指数退避等待,退避期间的请求直接返回 OfflineError
+type ReconnectPolicy struct {
+	// InitialDelay 首次失败后的等待时间,默认100ms
+	InitialDelay time.Duration
+	// MaxDelay 最大等待时间,默认30s
+	MaxDelay time.Duration
+	// Multiplier 每次失败后等待时间的倍数,默认2
+	Multiplier float64
+	// Jitter 等待时间的随机抖动比例,取值0~1,如0.2表示在±20%范围内随机
+	Jitter float64
+	// MaxAttempts 连续失败次数上限,达到后进入 StateOffline,0表示不限
+	MaxAttempts int
+	// Cooldown 进入 StateOffline 后的冷却时间,冷却结束后重新计数,默认为 MaxDelay
+	Cooldown time.Duration
+}
+
+// backoff 第attempt次连续失败后的等待时间
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialDelay
+	if delay <= 0 {
+		delay = defaultReconnectInitialDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultReconnectMultiplier
+	}
+	d := float64(delay)
+	for i := 1; i < attempt && d < float64(maxDelay); i++ {
+		d *= multiplier
+	}
+	if d > float64(maxDelay) {
+		d = float64(maxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+func (p *ReconnectPolicy) cooldown() time.Duration {
+	if p.Cooldown > 0 {
+		return p.Cooldown
+	}
+	if p.MaxDelay > 0 {
+		return p.MaxDelay
+	}
+	return defaultReconnectMaxDelay
+}
+
+// connectionMonitor 记录连接状态与连续失败次数,并在状态变化时调用回调
+// 由传输器在持有传输锁时调用,mu只保护状态查询
+type connectionMonitor struct {
+	mu       sync.Mutex
+	state    ConnectionState
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+func (m *connectionMonitor) State() ConnectionState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// allow 判断当前是否允许连接,退避或冷却期间返回 OfflineError
+func (m *connectionMonitor) allow(policy *ReconnectPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state != StateReconnecting && m.state != StateOffline {
+		return nil
+	}
+	if time.Now().Before(m.retryAt) {
+		return &OfflineError{State: m.state, Until: m.retryAt, Err: m.lastErr}
+	}
+	if m.state == StateOffline {
+		// 冷却结束,重新计数
+		m.failures = 0
+	}
+	return nil
+}
+
+// connected 连接成功
+func (m *connectionMonitor) connected(onConnect func()) {
+	m.mu.Lock()
+	m.state = StateConnected
+	m.failures = 0
+	m.lastErr = nil
+	m.mu.Unlock()
+	if onConnect != nil {
+		onConnect()
+	}
+}
+
+// failed 连接失败,按策略计算下一次允许连接的时间
+func (m *connectionMonitor) failed(policy *ReconnectPolicy, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastErr = err
+	if policy == nil {
+		m.state = StateDisconnected
+		return
+	}
+	m.failures++
+	if policy.MaxAttempts > 0 && m.failures >= policy.MaxAttempts {
+		m.state = StateOffline
+		m.retryAt = time.Now().Add(policy.cooldown())
+		return
+	}
+	m.state = StateReconnecting
+	m.retryAt = time.Now().Add(policy.backoff(m.failures))
+}
+
+// disconnected 已建立的连接断开,cause为nil表示主动关闭
+func (m *connectionMonitor) disconnected(cause error, onDisconnect func(err error)) {
+	m.mu.Lock()
+	m.state = StateDisconnected
+	m.mu.Unlock()
+	if onDisconnect != nil {
+		onDisconnect(cause)
+	}
+}
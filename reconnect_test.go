@@ -0,0 +1,108 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	policy := &ReconnectPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, d := range want {
+		if got := policy.backoff(i + 1); got != d*time.Millisecond {
+			t.Fatalf("attempt %v: got %v, want %v", i+1, got, d*time.Millisecond)
+		}
+	}
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(3); got < 320*time.Millisecond || got > 480*time.Millisecond {
+			t.Fatalf("jitter out of range: %v", got)
+		}
+	}
+}
+
+func TestTcpReconnectBackoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+	st := NewTcpTransporter(address)
+	st.Reconnect = &ReconnectPolicy{InitialDelay: 50 * time.Millisecond, MaxAttempts: 2, Cooldown: 100 * time.Millisecond}
+	c := NewModbusClient(NewTcpPackager(1), st)
+	var offline *OfflineError
+	// 首次连接失败后进入退避
+	if _, _, err = c.ReadHoldingRegisters(0, 1); err == nil || errors.As(err, &offline) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if st.State() != StateReconnecting {
+		t.Fatalf("unexpected state %v", st.State())
+	}
+	if _, _, err = c.ReadHoldingRegisters(0, 1); !errors.As(err, &offline) || offline.State != StateReconnecting || offline.Err == nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 退避结束后重连,失败次数达到上限进入冷却
+	time.Sleep(60 * time.Millisecond)
+	if _, _, err = c.ReadHoldingRegisters(0, 1); err == nil || errors.As(err, &offline) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, _, err = c.ReadHoldingRegisters(0, 1); !errors.As(err, &offline) || offline.State != StateOffline {
+		t.Fatalf("unexpected error %v", err)
+	}
+	time.Sleep(110 * time.Millisecond)
+	if _, _, err = c.ReadHoldingRegisters(0, 1); err == nil || errors.As(err, &offline) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if st.State() != StateReconnecting {
+		t.Fatalf("unexpected state %v", st.State())
+	}
+}
+
+func TestTcpConnectionCallbacks(t *testing.T) {
+	address := startScriptServer(t, func(conn net.Conn) {
+		request := make([]byte, 12)
+		_, _ = io.ReadFull(conn, request)
+		_, _ = conn.Write(tcpFrame(1, 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 1})))
+		// 第二个请求不响应,直接断开
+		_, _ = io.ReadFull(conn, request)
+	})
+	st := NewTcpTransporter(address)
+	var connects int
+	var causes []error
+	st.OnConnect = func() { connects++ }
+	st.OnDisconnect = func(err error) { causes = append(causes, err) }
+	c := NewModbusClient(NewTcpPackager(1), st)
+	if st.State() != StateDisconnected {
+		t.Fatalf("unexpected state %v", st.State())
+	}
+	if _, err := c.ReadHoldingRegisterValues(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if st.State() != StateConnected || connects != 1 {
+		t.Fatalf("unexpected state %v, connects %v", st.State(), connects)
+	}
+	if _, err := c.ReadHoldingRegisterValues(0, 1); err == nil {
+		t.Fatal("expected error")
+	}
+	if st.State() != StateDisconnected || len(causes) != 1 || causes[0] == nil {
+		t.Fatalf("unexpected state %v, causes %v", st.State(), causes)
+	}
+}
+
+func TestSerialDisconnectOnPortError(t *testing.T) {
+	c, port := newSerialTestClient(100 * time.Millisecond)
+	st := c.transporter.(*SerialPortTransporter)
+	var cause error
+	st.OnDisconnect = func(err error) { cause = err }
+	_ = port.Close()
+	if _, _, err := c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("expected error")
+	}
+	if st.Connected() || cause == nil {
+		t.Fatalf("port not closed, cause %v", cause)
+	}
+}
@@ -0,0 +1,168 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// FunctionDecoder 功能码响应解析规则,所有包解析器按此解析响应,串行链路按此预测响应帧长度
+type FunctionDecoder interface {
+	// ResponseLength 根据请求及已接收的功能码之后的响应数据,返回功能码之后的响应数据总长度
+	// 已接收的数据不足以确定长度时ok为false
+	ResponseLength(request ProtocolDataUnit, data []byte) (length int, ok bool)
+	// Decode 将功能码之后的响应数据解析为协议数据单元
+	Decode(functionCode byte, data []byte) (pdu ProtocolDataUnit, err error)
+}
+
+// fixedLengthDecoder 定长响应
+type fixedLengthDecoder int
+
+func (d fixedLengthDecoder) ResponseLength(request ProtocolDataUnit, data []byte) (length int, ok bool) {
+	return int(d), true
+}
+func (d fixedLengthDecoder) Decode(functionCode byte, data []byte) (pdu ProtocolDataUnit, err error) {
+	return NewProtocolDataUnit(functionCode, data), nil
+}
+
+// byteCountDecoder 以字节数字段开头的响应,字节数字段为1或2字节
+type byteCountDecoder int
+
+func (d byteCountDecoder) count(data []byte) int {
+	if d == 2 {
+		return int(binary.BigEndian.Uint16(data))
+	}
+	return int(data[0])
+}
+func (d byteCountDecoder) ResponseLength(request ProtocolDataUnit, data []byte) (length int, ok bool) {
+	if len(data) < int(d) {
+		return
+	}
+	return int(d) + d.count(data), true
+}
+func (d byteCountDecoder) Decode(functionCode byte, data []byte) (pdu ProtocolDataUnit, err error) {
+	if len(data) < int(d) {
+		err = fmt.Errorf("modbus: response data size '%v' less than byte count size '%v'", len(data), int(d))
+		return
+	}
+	pdu = protocolDataUnit{
+		functionCode: functionCode,
+		data:         data[d:],
+		length:       d.count(data),
+	}
+	return
+}
+
+// echoDecoder 原样返回请求数据的响应
+type echoDecoder struct{}
+
+func (d echoDecoder) ResponseLength(request ProtocolDataUnit, data []byte) (length int, ok bool) {
+	return len(request.GetData()), true
+}
+func (d echoDecoder) Decode(functionCode byte, data []byte) (pdu ProtocolDataUnit, err error) {
+	return NewProtocolDataUnit(functionCode, data), nil
+}
+
+// NewFixedLengthDecoder 创建定长响应解析规则,length为功能码之后的响应数据长度
+func NewFixedLengthDecoder(length int) FunctionDecoder {
+	return fixedLengthDecoder(length)
+}
+
+// NewByteCountDecoder 创建以字节数字段开头的响应解析规则,size为字节数字段长度(1或2)
+// 解析后的协议数据单元数据不含字节数字段,Length 为字节数
+func NewByteCountDecoder(size int) FunctionDecoder {
+	if size != 1 && size != 2 {
+		panic(fmt.Sprintf("modbus: byte count size '%v' must be 1 or 2", size))
+	}
+	return byteCountDecoder(size)
+}
+
+// NewEchoDecoder 创建原样返回请求数据的响应解析规则
+func NewEchoDecoder() FunctionDecoder {
+	return echoDecoder{}
+}
+
+var functionDecoders = struct {
+	sync.RWMutex
+	m map[byte]FunctionDecoder
+}{
+	m: map[byte]FunctionDecoder{
+		FuncCodeReadCoils:                  byteCountDecoder(1),
+		FuncCodeReadDiscreteInputs:         byteCountDecoder(1),
+		FuncCodeReadHoldingRegisters:       byteCountDecoder(1),
+		FuncCodeReadInputRegisters:         byteCountDecoder(1),
+		FuncCodeWriteSingleCoil:            fixedLengthDecoder(4),
+		FuncCodeWriteSingleRegister:        fixedLengthDecoder(4),
+		FuncCodeReadExceptionStatus:        fixedLengthDecoder(1),
+		FuncCodeDiagnostics:                echoDecoder{},
+		FuncCodeGetCommEventCounter:        fixedLengthDecoder(4),
+		FuncCodeGetCommEventLog:            byteCountDecoder(1),
+		FuncCodeWriteMultipleCoils:         fixedLengthDecoder(4),
+		FuncCodeWriteMultipleRegisters:     fixedLengthDecoder(4),
+		FuncCodeReportServerID:             byteCountDecoder(1),
+		FuncCodeReadFileRecord:             byteCountDecoder(1),
+		FuncCodeWriteFileRecord:            echoDecoder{},
+		FuncCodeMaskWriteRegister:          fixedLengthDecoder(6),
+		FuncCodeReadWriteMultipleRegisters: byteCountDecoder(1),
+		FuncCodeReadFIFOQueue:              byteCountDecoder(2),
+		FuncCodeEncapsulatedInterface:      deviceIdentificationDecoder{},
+	},
+}
+
+// RegisterFunctionDecoder 注册功能码响应解析规则,可覆盖内置规则,decoder为nil时删除
+// 未注册的功能码以功能码之后的全部数据作为协议数据单元数据,串行链路以帧间隔判断帧结束
+func RegisterFunctionDecoder(functionCode byte, decoder FunctionDecoder) {
+	if functionCode == 0 || functionCode&0x80 != 0 {
+		panic(fmt.Sprintf("modbus: function code '%v' must be between '%v' and '%v'", functionCode, 1, 127))
+	}
+	functionDecoders.Lock()
+	defer functionDecoders.Unlock()
+	if decoder == nil {
+		delete(functionDecoders.m, functionCode)
+		return
+	}
+	functionDecoders.m[functionCode] = decoder
+}
+
+func functionDecoder(functionCode byte) FunctionDecoder {
+	functionDecoders.RLock()
+	defer functionDecoders.RUnlock()
+	return functionDecoders.m[functionCode]
+}
+
+// decodePDU 按注册的解析规则解析功能码之后的响应数据,异常响应及未注册的功能码返回原始数据
+func decodePDU(mode ModbusMode, functionCode byte, data []byte) (pdu ProtocolDataUnit, err error) {
+	decoder := functionDecoder(functionCode)
+	if functionCode&0x80 != 0 || decoder == nil {
+		return NewProtocolDataUnit(functionCode, data), nil
+	}
+	pdu, err = decoder.Decode(functionCode, data)
+	if err != nil {
+		err = &FramingError{Mode: mode, Message: fmt.Sprintf("invalid response of functionCode '%X'", functionCode), Err: err}
+	}
+	return
+}
+
+// responsePredictable 判断能否按功能码预测响应长度
+func responsePredictable(request ProtocolDataUnit, functionCode byte) bool {
+	if functionCode == request.GetFunctionCode()|0x80 {
+		return true
+	}
+	return functionCode == request.GetFunctionCode() && functionDecoder(functionCode) != nil
+}
+
+// responseLength 预测功能码之后的响应数据长度,异常响应为1字节异常码
+// 功能码未注册或与请求不一致时ok为false
+func responseLength(request ProtocolDataUnit, functionCode byte, data []byte) (length int, ok bool) {
+	if functionCode == request.GetFunctionCode()|0x80 {
+		return 1, true
+	}
+	if functionCode != request.GetFunctionCode() {
+		return
+	}
+	decoder := functionDecoder(functionCode)
+	if decoder == nil {
+		return
+	}
+	return decoder.ResponseLength(request, data)
+}
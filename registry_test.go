@@ -0,0 +1,72 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendRawRegistered(t *testing.T) {
+	RegisterFunctionDecoder(0x41, NewByteCountDecoder(1))
+	defer RegisterFunctionDecoder(0x41, nil)
+	port := newTestPort()
+	port.timeout = 20 * time.Millisecond
+	st := &SerialPortTransporter{port: port}
+	st.BaudRate = 115200
+	c := NewModbusClient(NewRtuPackager(1), st)
+	// 响应分两次到达,按字节数预测帧长度后读取完整帧
+	frame := rtuFrame(1, 0x41, []byte{4, 1, 2, 3, 4})
+	port.reads <- frame[:5]
+	port.reads <- frame[5:]
+	request, results, err := c.SendRaw(0x41, []byte{0x10, 0x20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written := <-port.written; len(written) != 6 || written[1] != 0x41 || written[2] != 0x10 {
+		t.Fatalf("unexpected request %X", written)
+	}
+	pdu := results.GetPDU()
+	if request.GetFunctionCode() != 0x41 || pdu.Length() != 4 || len(pdu.GetData()) != 4 || pdu.GetData()[3] != 4 {
+		t.Fatalf("unexpected results %X length %d", pdu.GetData(), pdu.Length())
+	}
+}
+
+func TestSendRawUnregistered(t *testing.T) {
+	pk := NewTcpPackager(1)
+	results, err := pk.Decode(tcpFrame(1, 1, NewProtocolDataUnit(100, []byte{3, 1, 2})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdu := results.GetPDU(); pdu.GetFunctionCode() != 100 || pdu.Length() != 3 || pdu.GetData()[0] != 3 {
+		t.Fatalf("unexpected pdu %X", pdu.GetData())
+	}
+	c := NewModbusClient(pk, NewTcpTransporter("127.0.0.1:0"))
+	if _, _, err = c.SendRaw(0x81, nil); err == nil {
+		t.Fatal("expected function code error")
+	}
+}
+
+func TestRtuFrameLength(t *testing.T) {
+	pk := NewRtuPackager(1)
+	for _, tt := range []struct {
+		pdu    ProtocolDataUnit
+		frame  []byte
+		length int
+		ok     bool
+	}{
+		{NewProtocolDataUnit(FuncCodeReadHoldingRegisters, dataBlock(0, 2)), []byte{1, 3, 4}, 9, true},
+		{NewProtocolDataUnit(FuncCodeReadHoldingRegisters, dataBlock(0, 2)), []byte{1, 0x83}, 5, true},
+		{NewProtocolDataUnit(FuncCodeReadHoldingRegisters, dataBlock(0, 2)), []byte{1, 3}, 0, false},
+		{NewProtocolDataUnit(FuncCodeWriteSingleRegister, dataBlock(0, 2)), []byte{1, 6}, 8, true},
+		{NewProtocolDataUnit(FuncCodeReadFIFOQueue, dataBlock(0)), []byte{1, 24, 0, 6}, 12, true},
+		{NewProtocolDataUnit(FuncCodeDiagnostics, dataBlock(0, 1, 2)), []byte{1, 8}, 10, true},
+		{NewProtocolDataUnit(FuncCodeEncapsulatedInterface, []byte{0x0E, 1, 0}), []byte{1, 43, 0x0E, 1, 1, 0, 0, 2, 0, 1, 'a', 1}, 0, false},
+		{NewProtocolDataUnit(FuncCodeEncapsulatedInterface, []byte{0x0E, 1, 0}), []byte{1, 43, 0x0E, 1, 1, 0, 0, 2, 0, 1, 'a', 1, 2}, 17, true},
+		{NewProtocolDataUnit(100, nil), []byte{1, 100, 0}, 0, false},
+	} {
+		request, _ := pk.Encode(tt.pdu)
+		length, ok := rtuFrameLength(request, tt.frame)
+		if ok != tt.ok || (ok && length != tt.length) {
+			t.Fatalf("function code %d frame %X: got %d %v, want %d %v", tt.pdu.GetFunctionCode(), tt.frame, length, ok, tt.length, tt.ok)
+		}
+	}
+}
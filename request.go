@@ -0,0 +1,223 @@
+package modbus
+
+import (
+	"encoding/binary"
+)
+
+// Request 解析后的请求
+type Request struct {
+	SlaveID      byte
+	FunctionCode byte
+	// TransactionID 事务标识,仅TCP有效
+	TransactionID uint16
+	// Address 起始地址,读/写多个寄存器时为读起始地址
+	Address uint16
+	// Quantity 数量,读/写多个寄存器时为读数量
+	Quantity uint16
+	// WriteAddress 读/写多个寄存器时的写起始地址
+	WriteAddress uint16
+	// WriteQuantity 读/写多个寄存器时的写数量
+	WriteQuantity uint16
+	// Coils 写单个线圈、写多个线圈的值
+	Coils []bool
+	// AndMask OrMask 屏蔽写寄存器的与屏蔽、或屏蔽
+	AndMask uint16
+	OrMask  uint16
+	// ReadDeviceIDCode ObjectID 读设备识别码的读取类型与对象ID
+	ReadDeviceIDCode byte
+	ObjectID         byte
+	// SubFunction DiagnosticData 诊断的子功能码与数据
+	SubFunction    uint16
+	DiagnosticData []byte
+	// FileRecords 读文件记录、写文件记录的子请求,读取时 Data 为空
+	FileRecords []FileRecord
+	// Registers 写单个寄存器、写多个寄存器、读/写多个寄存器的写入值
+	Registers []uint16
+	// Data 协议数据单元中功能码之后的原始数据
+	Data []byte
+}
+
+// ParseRequest 按功能码解析请求协议数据单元
+// 数据长度、字节数或数量非法时返回 ExceptionIllegalDataValue,地址越界时返回 ExceptionIllegalDataAddress
+// 读/写文件记录解析为 FileRecords,诊断解析为 SubFunction 与 DiagnosticData
+// 未知功能码只填充 FunctionCode 与 Data
+func ParseRequest(pdu ProtocolDataUnit) (request *Request, err error) {
+	data := pdu.GetData()
+	request = &Request{
+		FunctionCode: pdu.GetFunctionCode(),
+		Data:         data,
+	}
+	switch request.FunctionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		if len(data) != 4 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		err = checkRange(request.Address, request.Quantity, 2000)
+	case FuncCodeReadInputRegisters, FuncCodeReadHoldingRegisters:
+		if len(data) != 4 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		err = checkRange(request.Address, request.Quantity, 125)
+	case FuncCodeWriteSingleCoil:
+		if len(data) != 4 {
+			return request, ExceptionIllegalDataValue
+		}
+		coil := binary.BigEndian.Uint16(data[2:])
+		if coil != 0xFF00 && coil != 0x0000 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), 1
+		request.Coils = []bool{coil == 0xFF00}
+	case FuncCodeWriteSingleRegister:
+		if len(data) != 4 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), 1
+		request.Registers = []uint16{binary.BigEndian.Uint16(data[2:])}
+	case FuncCodeMaskWriteRegister:
+		if len(data) != 6 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), 1
+		request.AndMask, request.OrMask = binary.BigEndian.Uint16(data[2:]), binary.BigEndian.Uint16(data[4:])
+	case FuncCodeReadFIFOQueue:
+		if len(data) != 2 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address = binary.BigEndian.Uint16(data)
+	case FuncCodeReadExceptionStatus, FuncCodeGetCommEventCounter, FuncCodeGetCommEventLog, FuncCodeReportServerID:
+		if len(data) != 0 {
+			return request, ExceptionIllegalDataValue
+		}
+	case FuncCodeDiagnostics:
+		// 返回询问数据的数据长度任意,其它子功能码数据固定为2字节
+		if len(data) < 2 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.SubFunction, request.DiagnosticData = binary.BigEndian.Uint16(data), data[2:]
+		if request.SubFunction != DiagReturnQueryData && len(request.DiagnosticData) != 2 {
+			return request, ExceptionIllegalDataValue
+		}
+	case FuncCodeReadFileRecord, FuncCodeWriteFileRecord:
+		request.FileRecords, err = parseFileRecordRequest(data, request.FunctionCode == FuncCodeWriteFileRecord)
+	case FuncCodeEncapsulatedInterface:
+		// 仅支持读设备识别码
+		if len(data) < 1 || data[0] != meiReadDeviceIdentification {
+			return request, ExceptionIllegalFunction
+		}
+		if len(data) != 3 || data[1] < ReadDeviceIDBasic || data[1] > ReadDeviceIDSpecific {
+			return request, ExceptionIllegalDataValue
+		}
+		request.ReadDeviceIDCode, request.ObjectID = data[1], data[2]
+	case FuncCodeWriteMultipleCoils:
+		if len(data) < 6 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		count := int(data[4])
+		if count != (int(request.Quantity)+7)/8 || len(data) != 5+count {
+			return request, ExceptionIllegalDataValue
+		}
+		if err = checkRange(request.Address, request.Quantity, 1968); err != nil {
+			return
+		}
+		request.Coils = fromBit(data[5:], int(request.Quantity))
+	case FuncCodeWriteMultipleRegisters:
+		if len(data) < 7 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		count := int(data[4])
+		if count != int(request.Quantity)*2 || len(data) != 5+count {
+			return request, ExceptionIllegalDataValue
+		}
+		if err = checkRange(request.Address, request.Quantity, 123); err != nil {
+			return
+		}
+		request.Registers = registers(data[5:])
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(data) < 11 {
+			return request, ExceptionIllegalDataValue
+		}
+		request.Address, request.Quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		request.WriteAddress, request.WriteQuantity = binary.BigEndian.Uint16(data[4:]), binary.BigEndian.Uint16(data[6:])
+		count := int(data[8])
+		if count != int(request.WriteQuantity)*2 || len(data) != 9+count {
+			return request, ExceptionIllegalDataValue
+		}
+		if err = checkRange(request.Address, request.Quantity, 125); err != nil {
+			return
+		}
+		if err = checkRange(request.WriteAddress, request.WriteQuantity, 121); err != nil {
+			return
+		}
+		request.Registers = registers(data[9:])
+	}
+	return
+}
+
+// checkRange 校验数量范围及地址是否越界
+func checkRange(address, quantity, max uint16) error {
+	if quantity < 1 || quantity > max {
+		return ExceptionIllegalDataValue
+	}
+	if int(address)+int(quantity) > 65536 {
+		return ExceptionIllegalDataAddress
+	}
+	return nil
+}
+
+func registers(data []byte) []uint16 {
+	value := make([]uint16, len(data)/2)
+	for i := range value {
+		value[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return value
+}
+
+// parseFileRecordRequest 解析读/写文件记录请求的子请求
+// 字节数或子请求长度非法时返回 ExceptionIllegalDataValue,参考类型、文件号或记录号非法时返回 ExceptionIllegalDataAddress
+func parseFileRecordRequest(data []byte, write bool) (records []FileRecord, err error) {
+	if len(data) < 1 || len(data) != 1+int(data[0]) {
+		return nil, ExceptionIllegalDataValue
+	}
+	// 读请求字节数为子请求长度的整数倍且不超过0xF5,写请求字节数受协议数据单元长度限制
+	count, limit := int(data[0]), fileRecordMaxDataLength
+	if write {
+		limit = pduMaxSize - 2
+	} else if count%fileRecordRequestSize != 0 {
+		return nil, ExceptionIllegalDataValue
+	}
+	if count < fileRecordRequestSize || count > limit {
+		return nil, ExceptionIllegalDataValue
+	}
+	for data = data[1:]; len(data) > 0; {
+		if len(data) < fileRecordRequestSize {
+			return nil, ExceptionIllegalDataValue
+		}
+		record := FileRecord{
+			FileNumber:   binary.BigEndian.Uint16(data[1:]),
+			RecordNumber: binary.BigEndian.Uint16(data[3:]),
+			Length:       binary.BigEndian.Uint16(data[5:]),
+		}
+		size := fileRecordRequestSize
+		if write {
+			size += int(record.Length) * 2
+			if len(data) < size {
+				return nil, ExceptionIllegalDataValue
+			}
+			record.Data = registers(data[fileRecordRequestSize:size])
+		}
+		if record.Length < 1 {
+			return nil, ExceptionIllegalDataValue
+		}
+		if data[0] != fileRecordReferenceType || record.FileNumber == 0 || int(record.RecordNumber)+int(record.Length)-1 > fileRecordMaxNumber {
+			return nil, ExceptionIllegalDataAddress
+		}
+		records = append(records, record)
+		data = data[size:]
+	}
+	return
+}
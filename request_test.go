@@ -0,0 +1,106 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	pdu := protocolDataUnit{
+		functionCode: FuncCodeReadWriteMultipleRegisters,
+		data:         dataBlockSuffix(dataBlock(0x0102, 0x0304), 10, 3, 20, 2),
+	}
+	for _, packager := range []Packager{NewTcpPackager(5), NewRtuPackager(5), NewAsciiPackager(5)} {
+		adu, err := packager.Encode(pdu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, request, err := packager.DecodeRequest(adu.GetData())
+		if err != nil {
+			t.Fatalf("%s: %v", adu.GetMode(), err)
+		}
+		if request.SlaveID != 5 || request.FunctionCode != FuncCodeReadWriteMultipleRegisters ||
+			request.Address != 10 || request.Quantity != 3 || request.WriteAddress != 20 || request.WriteQuantity != 2 ||
+			len(request.Registers) != 2 || request.Registers[0] != 0x0102 || request.Registers[1] != 0x0304 {
+			t.Fatalf("%s: unexpected request %+v", adu.GetMode(), request)
+		}
+	}
+}
+
+func TestDecodeRequestInvalid(t *testing.T) {
+	// 字节数与数量不一致
+	adu, _ := NewRtuPackager(1).Encode(protocolDataUnit{
+		functionCode: FuncCodeWriteMultipleCoils,
+		data:         dataBlockSuffix([]byte{0x01}, 0, 9),
+	})
+	_, request, err := NewRtuPackager(1).DecodeRequest(adu.GetData())
+	if request == nil || !errors.Is(err, ExceptionIllegalDataValue) {
+		t.Fatalf("expected illegal data value, got %v", err)
+	}
+	// crc错误
+	frame := adu.GetData()
+	frame[len(frame)-1]++
+	if _, request, err = NewRtuPackager(1).DecodeRequest(frame); request != nil || err == nil {
+		t.Fatal("expected crc error")
+	}
+}
+
+func TestParseRequestDiagnostics(t *testing.T) {
+	request, err := ParseRequest(diagnosticsPDU(DiagReturnQueryData, []byte{0xA5, 0x37, 0x42}))
+	if err != nil || request.SubFunction != DiagReturnQueryData || string(request.DiagnosticData) != "\xA5\x37\x42" {
+		t.Fatalf("unexpected request %+v %v", request, err)
+	}
+	request, err = ParseRequest(diagnosticsPDU(DiagRestartCommunications, dataBlock(0xFF00)))
+	if err != nil || request.SubFunction != DiagRestartCommunications || len(request.DiagnosticData) != 2 {
+		t.Fatalf("unexpected request %+v %v", request, err)
+	}
+	for _, pdu := range []protocolDataUnit{
+		{functionCode: FuncCodeDiagnostics, data: []byte{0}},
+		diagnosticsPDU(DiagClearCounters, nil),
+		diagnosticsPDU(DiagBusMessageCount, []byte{0, 0, 0}),
+		{functionCode: FuncCodeReadExceptionStatus, data: []byte{0}},
+		{functionCode: FuncCodeReportServerID, data: []byte{0, 0}},
+	} {
+		if _, err = ParseRequest(pdu); !errors.Is(err, ExceptionIllegalDataValue) {
+			t.Fatalf("%X % x: expected illegal data value, got %v", pdu.functionCode, pdu.data, err)
+		}
+	}
+	if _, err = ParseRequest(protocolDataUnit{functionCode: FuncCodeGetCommEventLog}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseRequestFileRecord(t *testing.T) {
+	read := []byte{14, 6, 0, 4, 0, 1, 0, 2, 6, 0, 3, 0, 9, 0, 2}
+	request, err := ParseRequest(protocolDataUnit{functionCode: FuncCodeReadFileRecord, data: read})
+	if err != nil || len(request.FileRecords) != 2 || request.FileRecords[1].FileNumber != 3 || request.FileRecords[1].RecordNumber != 9 || request.FileRecords[1].Length != 2 {
+		t.Fatalf("unexpected request %+v %v", request, err)
+	}
+	write := []byte{13, 6, 0, 4, 0, 7, 0, 3, 0x06, 0xAF, 0x04, 0xBE, 0x10, 0x0D}
+	request, err = ParseRequest(protocolDataUnit{functionCode: FuncCodeWriteFileRecord, data: write})
+	if err != nil || len(request.FileRecords) != 1 || request.FileRecords[0].RecordNumber != 7 || len(request.FileRecords[0].Data) != 3 || request.FileRecords[0].Data[2] != 0x100D {
+		t.Fatalf("unexpected request %+v %v", request, err)
+	}
+	for _, tt := range []struct {
+		functionCode byte
+		data         []byte
+		err          error
+	}{
+		{FuncCodeReadFileRecord, nil, ExceptionIllegalDataValue},
+		// 字节数与数据长度不一致
+		{FuncCodeReadFileRecord, read[:10], ExceptionIllegalDataValue},
+		// 字节数不是子请求长度的整数倍
+		{FuncCodeReadFileRecord, []byte{8, 6, 0, 4, 0, 1, 0, 2, 0}, ExceptionIllegalDataValue},
+		{FuncCodeReadFileRecord, []byte{7, 6, 0, 4, 0, 1, 0, 0}, ExceptionIllegalDataValue},
+		{FuncCodeReadFileRecord, []byte{7, 5, 0, 4, 0, 1, 0, 2}, ExceptionIllegalDataAddress},
+		{FuncCodeReadFileRecord, []byte{7, 6, 0, 0, 0, 1, 0, 2}, ExceptionIllegalDataAddress},
+		{FuncCodeReadFileRecord, []byte{7, 6, 0, 4, 0x27, 0x0F, 0, 2}, ExceptionIllegalDataAddress},
+		// 记录值长度与记录数量不一致
+		{FuncCodeWriteFileRecord, append([]byte{12}, write[1:13]...), ExceptionIllegalDataValue},
+		{FuncCodeWriteFileRecord, append([]byte{14}, append(write[1:], 0)...), ExceptionIllegalDataValue},
+	} {
+		if _, err = ParseRequest(protocolDataUnit{functionCode: tt.functionCode, data: tt.data}); !errors.Is(err, tt.err) {
+			t.Fatalf("% x: expected %v, got %v", tt.data, tt.err, err)
+		}
+	}
+}
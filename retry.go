@@ -0,0 +1,130 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// defaultRetryAttempts 默认最大尝试次数
+const defaultRetryAttempts = 3
+
+// RetryPolicy 重试策略,超时、CRC/LRC校验失败及从站忙(06)、确认(05)异常时重发请求
+// 重发使用相同的应用数据单元,非幂等请求默认不重试
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数,包括首次发送,默认3
+	MaxAttempts int
+	// Delay 重试前的等待时间
+	Delay time.Duration
+	// BusyDelay 从站忙或确认异常后的等待时间,默认与 Delay 相同
+	BusyDelay time.Duration
+	// RetryNonIdempotent 允许重试非幂等请求,如重启通信、清除计数器及自定义功能码
+	// 这类请求的响应丢失时从站可能已执行,重发会导致重复执行
+	RetryNonIdempotent bool
+	// Retryable 自定义可重试的错误,为nil时使用默认判断
+	Retryable func(err error) bool
+	// OnAttempt 每次尝试后调用,attempt从1开始,err为本次错误,retry表示是否将重试
+	OnAttempt func(request ApplicationDataUnit, attempt int, err error, retry bool)
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return defaultRetryAttempts
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return retryableError(err)
+}
+
+// delay 本次错误后的等待时间
+func (p *RetryPolicy) delay(err error) time.Duration {
+	if p.BusyDelay > 0 && (errors.Is(err, ErrServerDeviceBusy) || errors.Is(err, ErrAcknowledge)) {
+		return p.BusyDelay
+	}
+	return p.Delay
+}
+
+// retryableError 默认可重试的错误:超时、校验失败、从站忙及确认异常
+func retryableError(err error) bool {
+	var timeout *TimeoutError
+	var checksum *ChecksumError
+	return errors.As(err, &timeout) ||
+		errors.As(err, &checksum) ||
+		errors.Is(err, ErrServerDeviceBusy) ||
+		errors.Is(err, ErrAcknowledge)
+}
+
+// idempotent 判断请求重复执行是否与执行一次效果相同,自定义功能码视为非幂等
+func idempotent(pdu ProtocolDataUnit) bool {
+	switch pdu.GetFunctionCode() {
+	case FuncCodeReadCoils,
+		FuncCodeReadDiscreteInputs,
+		FuncCodeReadHoldingRegisters,
+		FuncCodeReadInputRegisters,
+		FuncCodeWriteSingleCoil,
+		FuncCodeWriteSingleRegister,
+		FuncCodeWriteMultipleCoils,
+		FuncCodeWriteMultipleRegisters,
+		FuncCodeMaskWriteRegister,
+		FuncCodeReadWriteMultipleRegisters,
+		FuncCodeReadFileRecord,
+		FuncCodeWriteFileRecord,
+		FuncCodeReadFIFOQueue,
+		FuncCodeEncapsulatedInterface,
+		FuncCodeReadExceptionStatus,
+		FuncCodeGetCommEventCounter,
+		FuncCodeGetCommEventLog,
+		FuncCodeReportServerID:
+		return true
+	case FuncCodeDiagnostics:
+		data := pdu.GetData()
+		if len(data) < 2 {
+			return false
+		}
+		subFunction := binary.BigEndian.Uint16(data)
+		return subFunction == DiagReturnQueryData ||
+			subFunction == DiagReturnDiagnosticRegister ||
+			subFunction >= DiagBusMessageCount && subFunction <= DiagBusCharacterOverrunCount
+	}
+	return false
+}
+
+// sendWithRetry 按重试策略发送请求,发生重试时返回的 RetryError 包含每次尝试的错误
+func (c *ModbusClient) sendWithRetry(request ApplicationDataUnit) (results ApplicationDataUnit, err error) {
+	policy := c.retry
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	allowed := policy.RetryNonIdempotent || idempotent(request.GetPDU())
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		results, err = c.exchange(request)
+		retry := err != nil && allowed && attempt < policy.attempts() && policy.retryable(err) && contextErr(ctx) == nil
+		if policy.OnAttempt != nil {
+			policy.OnAttempt(request, attempt, err, retry)
+		}
+		if err == nil {
+			return
+		}
+		errs = append(errs, err)
+		if retry {
+			if e := sleepContext(ctx, policy.delay(err)); e != nil {
+				errs = append(errs, e)
+				retry = false
+			}
+		}
+		if !retry {
+			if len(errs) > 1 {
+				err = &RetryError{Errors: errs}
+			}
+			return
+		}
+	}
+}
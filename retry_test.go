@@ -0,0 +1,119 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// scriptTransporter 按顺序返回预设响应的传输器,respond返回nil时视为超时
+type scriptTransporter struct {
+	respond []func(request ApplicationDataUnit) []byte
+	sent    int
+}
+
+func (t *scriptTransporter) Open() error     { return nil }
+func (t *scriptTransporter) Connected() bool { return true }
+func (t *scriptTransporter) Close() error    { return nil }
+func (t *scriptTransporter) Send(request ApplicationDataUnit) (aduResponse []byte, err error) {
+	respond := t.respond[t.sent]
+	t.sent++
+	if aduResponse = respond(request); aduResponse == nil {
+		err = &TimeoutError{}
+	}
+	return
+}
+
+func respondPDU(pdu ProtocolDataUnit) func(request ApplicationDataUnit) []byte {
+	return func(request ApplicationDataUnit) []byte {
+		return tcpFrame(binary.BigEndian.Uint16(request.GetData()), request.GetSlaveId(), pdu)
+	}
+}
+
+func respondTimeout(request ApplicationDataUnit) []byte {
+	return nil
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{
+		respondTimeout,
+		respondPDU(NewProtocolDataUnit(FuncCodeReadHoldingRegisters|0x80, []byte{byte(ExceptionServerDeviceBusy)})),
+		respondPDU(NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 7})),
+	}}
+	var attempts []error
+	var retries []bool
+	c := NewModbusClient(NewTcpPackager(1), st).WithRetry(&RetryPolicy{
+		Delay:     time.Millisecond,
+		BusyDelay: 10 * time.Millisecond,
+		OnAttempt: func(request ApplicationDataUnit, attempt int, err error, retry bool) {
+			attempts = append(attempts, err)
+			retries = append(retries, retry)
+		},
+	})
+	value, err := c.ReadHoldingRegisterValues(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value[0] != 7 || st.sent != 3 {
+		t.Fatalf("unexpected value %v after '%v' attempts", value, st.sent)
+	}
+	if len(attempts) != 3 || attempts[2] != nil || !retries[0] || !retries[1] || retries[2] {
+		t.Fatalf("unexpected attempts %v %v", attempts, retries)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{
+		respondTimeout,
+		respondPDU(NewProtocolDataUnit(FuncCodeReadHoldingRegisters|0x80, []byte{byte(ExceptionAcknowledge)})),
+		respondTimeout,
+	}}
+	c := NewModbusClient(NewTcpPackager(1), st).WithRetry(&RetryPolicy{})
+	_, _, err := c.ReadHoldingRegisters(0, 1)
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || len(retryErr.Errors) != 3 || st.sent != 3 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var timeout *TimeoutError
+	if !errors.Is(err, ErrAcknowledge) || !errors.As(err, &timeout) {
+		t.Fatalf("error %v does not wrap attempts", err)
+	}
+}
+
+func TestRetryNonRetryableError(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{
+		respondPDU(NewProtocolDataUnit(FuncCodeReadHoldingRegisters|0x80, []byte{byte(ExceptionIllegalDataAddress)})),
+	}}
+	c := NewModbusClient(NewTcpPackager(1), st).WithRetry(&RetryPolicy{})
+	_, _, err := c.ReadHoldingRegisters(0, 1)
+	var exception *ExceptionError
+	if !errors.As(err, &exception) || st.sent != 1 {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{respondTimeout, respondTimeout}}
+	c := NewModbusClient(NewTcpPackager(1), st).WithRetry(&RetryPolicy{})
+	if err := c.ClearCounters(); err == nil || st.sent != 1 {
+		t.Fatalf("unexpected error %v after '%v' attempts", err, st.sent)
+	}
+	c = NewModbusClient(NewTcpPackager(1), st).WithRetry(&RetryPolicy{MaxAttempts: 2, RetryNonIdempotent: true})
+	st.sent = 0
+	if _, _, err := c.SendRaw(0x41, nil); err == nil || st.sent != 2 {
+		t.Fatalf("unexpected error %v after '%v' attempts", err, st.sent)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{respondTimeout, respondTimeout}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	c := NewModbusClient(NewTcpPackager(1), st).WithContext(ctx).WithRetry(&RetryPolicy{Delay: time.Second})
+	_, _, err := c.ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, context.DeadlineExceeded) || st.sent != 1 {
+		t.Fatalf("unexpected error %v after '%v' attempts", err, st.sent)
+	}
+}
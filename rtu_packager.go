@@ -2,7 +2,6 @@ package modbus
 
 import (
 	"bytes"
-	"encoding/hex"
 	"fmt"
 )
 
@@ -16,7 +15,7 @@ type rtuPackager struct {
 	slaveID byte
 }
 
-func (p *rtuPackager) Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, err error) {
+func (p *rtuPackager) Encode(pdu ProtocolDataUnit) (adu ApplicationDataUnit, err error) {
 	length := len(pdu.GetData()) + rtuMinSize
 	if length > rtuMaxSize {
 		err = fmt.Errorf("modbus: length of data '%v' must not be bigger than '%v'", length, rtuMaxSize)
@@ -43,34 +42,18 @@ func (p *rtuPackager) Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, err
 func (p *rtuPackager) Decode(results []byte) (adu ApplicationDataUnit, err error) {
 	length := len(results)
 	if length > rtuMaxSize {
-		err = fmt.Errorf("modbus: response data size '%v' exceeds the maximum limit of '%v'", length, rtuMaxSize)
+		err = framingError(RTU, "response data size '%v' exceeds the maximum limit of '%v'", length, rtuMaxSize)
 		return
 	}
 	if length < rtuMinSize {
-		err = fmt.Errorf("modbus: response data size '%v' less than maximum limit of '%v'", length, rtuMinSize)
+		err = framingError(RTU, "response data size '%v' less than minimum limit of '%v'", length, rtuMinSize)
 		return
 	}
 	slaveID := results[0]
 	functionCode := results[1]
-	var pduLength int
-	var pdu protocolDataUnit
-	switch functionCode {
-	//read
-	case FuncCodeReadDiscreteInputs, FuncCodeReadCoils, FuncCodeReadInputRegisters, FuncCodeReadHoldingRegisters:
-		pduLength = int(results[2])
-		pduData := results[3 : length-2]
-		pdu = protocolDataUnit{
-			functionCode: functionCode,
-			data:         pduData,
-			length:       pduLength,
-		}
-	default:
-		pduData := results[2 : length-2]
-		pdu = protocolDataUnit{
-			functionCode: functionCode,
-			data:         pduData,
-			length:       len(pduData),
-		}
+	pdu, err := decodePDU(RTU, functionCode, results[2:length-2])
+	if err != nil {
+		return
 	}
 	checkSumByte := results[length-2:]
 	adu = applicationDataUnit{
@@ -84,41 +67,71 @@ func (p *rtuPackager) Decode(results []byte) (adu ApplicationDataUnit, err error
 	}
 	return
 }
-func (p *rtuPackager) Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
-	if aduRequest.GetSlaveId() != aduResponse.GetSlaveId() {
-		err = fmt.Errorf("modbus: aduRequest  slaveId '%v' and aduResponse slaveId '%v' are inconsistent", aduRequest.GetSlaveId(), aduResponse.GetSlaveId())
+func (p *rtuPackager) DecodeRequest(results []byte) (adu ApplicationDataUnit, request *Request, err error) {
+	length := len(results)
+	if length > rtuMaxSize {
+		err = framingError(RTU, "request data size '%v' exceeds the maximum limit of '%v'", length, rtuMaxSize)
 		return
 	}
-	if aduRequest.GetFunctionCode() != aduResponse.GetFunctionCode() {
-		if aduResponse.GetFunctionCode() == aduRequest.GetFunctionCode()+0x80 {
-			err = fmt.Errorf("modbus: error   errorCode '%X'", aduResponse.GetFunctionCode())
-			data := aduResponse.GetPDU().GetData()
-			if len(data) == 0 {
-				return
-			}
-			text, exist := faults[data[0]]
-			if !exist {
-				return
-			}
-			err = fmt.Errorf("modbus: error errorCode: '%X'  %s", aduResponse.GetFunctionCode(), text)
-			return
-		}
-		err = fmt.Errorf("modbus: aduRequest  functionCode '%v' and aduResponse functionCode '%v' are inconsistent", aduRequest.GetFunctionCode(), aduResponse.GetFunctionCode())
+	if length < rtuMinSize {
+		err = framingError(RTU, "request data size '%v' less than minimum limit of '%v'", length, rtuMinSize)
 		return
 	}
+	checkSumByte := results[length-2:]
+	checksum := CRC16(results[:length-2])
+	if checksum != CRC16ToUint(checkSumByte) {
+		err = &ChecksumError{Mode: RTU, Source: checkSumByte, Reality: CRC16ToBytes(checksum)}
+		return
+	}
+	slaveID := results[0]
+	pduData := results[2 : length-2]
+	pdu := protocolDataUnit{
+		functionCode: results[1],
+		data:         pduData,
+		length:       len(pduData),
+	}
+	adu = applicationDataUnit{
+		slaveID:      slaveID,
+		pdu:          pdu,
+		checkSumByte: checkSumByte,
+		checkSum:     CRC16ToUint(checkSumByte),
+		data:         results,
+		mode:         RTU,
+		length:       length,
+	}
+	request, err = ParseRequest(pdu)
+	request.SlaveID = slaveID
+	return
+}
+func (p *rtuPackager) Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
 	data := aduResponse.GetData()
 	length := len(data)
-	checksum := CRC16(aduResponse.GetData()[:length-2])
+	checksum := CRC16(data[:length-2])
 	if checksum != aduResponse.GetCheckSum() {
-		sumByte := make([]byte, 2)
-		sumByte[0] = byte(checksum >> 8)
-		sumByte[1] = byte(checksum)
-		err = fmt.Errorf("modbus: crc validation failed source:'%v' reality:'%v' ", hex.EncodeToString(aduResponse.GetCheckSumByte()), hex.EncodeToString(sumByte))
+		err = &ChecksumError{Mode: RTU, Source: aduResponse.GetCheckSumByte(), Reality: CRC16ToBytes(checksum)}
 		return
 	}
+	if aduRequest.GetSlaveId() != aduResponse.GetSlaveId() {
+		err = fmt.Errorf("modbus: aduRequest  slaveId '%v' and aduResponse slaveId '%v' are inconsistent", aduRequest.GetSlaveId(), aduResponse.GetSlaveId())
+		return
+	}
+	err = verifyException(aduRequest, aduResponse)
 	return
 }
 
+// rtuFrameLength 根据请求及已接收的响应预测RTU响应帧总长度,无法预测时ok为false
+func rtuFrameLength(request ApplicationDataUnit, frame []byte) (length int, ok bool) {
+	if len(frame) < 2 {
+		return
+	}
+	length, ok = responseLength(request.GetPDU(), frame[1], frame[2:])
+	return 2 + length + 2, ok
+}
+
+func (p *rtuPackager) ForUnit(slaveID byte) Packager {
+	return &rtuPackager{slaveID: slaveID}
+}
+
 func NewRtuPackager(slaveID byte) (p Packager) {
 	p = &rtuPackager{slaveID: slaveID}
 	return
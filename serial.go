@@ -2,52 +2,82 @@ package modbus
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"go.bug.st/serial"
-	"sync"
 	"time"
 )
 
 const (
 	defaultSerialReadTimeout = time.Second
 	defaultBaudRate          = 9600
-	// 开始3.5字符 地址8位 功能码8位 长度8位 CRC效验16位 结束3.5字符
-	rtuMinByteLen int64 = 1 + 1 + 2
-	// 串口传输一个字节位bit的长度 开始位固定为1 数据位8 奇偶校验位1 停止位1
-	serialByteLen int64 = 1 + 8 + 1 + 1
 )
 
 type SerialPortTransporter struct {
 	PortName string
 	serial.Mode
+	// ReadTimeout 等待响应超时,默认1s
 	ReadTimeout time.Duration
-	port        serial.Port
-	mu          sync.Mutex
+	// TurnaroundDelay 广播后到下一次请求的等待时间,默认100ms
+	TurnaroundDelay time.Duration
+	// Timing 时序参数,非零值覆盖按 Mode 计算的值,见 EffectiveTiming
+	Timing SerialTiming
+	// Reconnect 重连策略,为nil时打开失败后下次发送立即重试
+	Reconnect *ReconnectPolicy
+	// OnConnect 串口打开后调用,OnDisconnect 串口关闭后调用,err为关闭原因,主动关闭时为nil
+	// 回调在发送请求的协程中同步执行,不能在回调中使用同一传输器发送请求
+	OnConnect    func()
+	OnDisconnect func(err error)
+	monitor      connectionMonitor
+	port         serial.Port
+	mu           transportLock
+	// idle 广播转换延时结束时间
+	idle time.Time
 }
 
 func (t *SerialPortTransporter) Open() error {
-	t.mu.Lock()
+	_ = t.mu.Lock(context.Background())
 	defer t.mu.Unlock()
 	return t.open()
 }
+
+// open 打开串口,重连退避期间返回 OfflineError
 func (t *SerialPortTransporter) open() error {
+	if err := t.monitor.allow(t.Reconnect); err != nil {
+		return err
+	}
 	port, err := serial.Open(t.PortName, &t.Mode)
-	if err == nil {
-		readTimeout := defaultSerialReadTimeout
-		if t.ReadTimeout > 0 {
-			readTimeout = t.ReadTimeout
-		}
-		_ = port.SetReadTimeout(readTimeout)
-		t.port = port
+	if err != nil {
+		t.monitor.failed(t.Reconnect, err)
+		return err
 	}
-	return err
+	_ = port.SetReadTimeout(t.EffectiveTiming().ResponseTimeout)
+	t.port = port
+	t.monitor.connected(t.OnConnect)
+	return nil
 }
+
 func (t *SerialPortTransporter) Connected() bool {
 	return t.port != nil
 }
+
+// State 返回连接状态,可在其他协程中调用
+func (t *SerialPortTransporter) State() ConnectionState {
+	return t.monitor.State()
+}
 func (t *SerialPortTransporter) Send(aduRequest ApplicationDataUnit) (aduResponse []byte, err error) {
-	t.mu.Lock()
+	return t.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext 发送请求并等待响应,ctx取消时关闭串口以中止读取,下次发送时重新打开
+func (t *SerialPortTransporter) SendContext(ctx context.Context, aduRequest ApplicationDataUnit) (aduResponse []byte, err error) {
+	if err = t.mu.Lock(ctx); err != nil {
+		return
+	}
 	defer t.mu.Unlock()
+	if err = waitTurnaround(ctx, t.idle); err != nil {
+		return
+	}
 	if !t.Connected() {
 		err = t.open()
 		if err != nil {
@@ -58,81 +88,201 @@ func (t *SerialPortTransporter) Send(aduRequest ApplicationDataUnit) (aduRespons
 			return
 		}
 	}
-	err = t.port.ResetInputBuffer()
+	port := t.port
+	stop := watchContext(ctx, func() { _ = port.Close() })
+	defer stop()
+	defer func() {
+		if err == nil {
+			return
+		}
+		if contextErr(ctx) != nil {
+			err = contextErr(ctx)
+			_ = t.disconnect(err)
+			return
+		}
+		switch err.(type) {
+		case *TimeoutError, *FramingError:
+		default:
+			// 串口读写出错,如USB转串口设备被拔出,关闭串口以便下次发送时重新打开
+			_ = t.disconnect(err)
+		}
+	}()
+	err = port.ResetInputBuffer()
 	if err != nil {
 		return
 	}
-	_, err = t.port.Write(aduRequest.GetData())
+	_, err = port.Write(aduRequest.GetData())
 	if err != nil {
 		return
 	}
-	sleep := t.calculateDelay(aduRequest)
-	time.Sleep(sleep)
-	var n int
-	buf := bytes.NewBuffer([]byte{})
-	for true {
-		temp := make([]byte, rtuMaxSize*2)
-		n, err = t.port.Read(temp)
-		if err != nil {
-			return
+	if aduRequest.GetMode() == ASCII {
+		return t.readAsciiFrame(port)
+	}
+	return t.readRtuFrame(port, aduRequest)
+}
+
+// readRtuFrame 读取一帧RTU响应,按从站地址、功能码及字节数或异常码预测帧长度,读满后立即返回
+// 首字节到达前按响应超时等待,之后字符间隔超过 InterCharTimeout(t1.5) 时丢弃整帧,帧不完整时返回 TimeoutError
+// 无法预测长度的功能码以3.5个字符的帧间隔判断帧结束,t1.5至t3.5之间又收到数据时返回 FramingError
+func (t *SerialPortTransporter) readRtuFrame(port serial.Port, aduRequest ApplicationDataUnit) (frame []byte, err error) {
+	timing := t.EffectiveTiming()
+	if err = port.SetReadTimeout(timing.ResponseTimeout); err != nil {
+		return
+	}
+	frame = make([]byte, 0, rtuMaxSize)
+	temp := make([]byte, rtuMaxSize)
+	silence := false
+	gap := false
+	expected := 0
+	for {
+		var n int
+		if n, err = port.Read(temp[:rtuMaxSize-len(frame)]); err != nil {
+			return nil, err
 		}
 		if n == 0 {
-			break
+			if len(frame) == 0 {
+				return nil, &TimeoutError{}
+			}
+			if silence {
+				if gap || timing.frameGap() == 0 {
+					return frame, nil
+				}
+				// 已超过t1.5,继续等待至t3.5以确认帧结束
+				gap = true
+				if err = port.SetReadTimeout(timing.frameGap()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if expected == 0 {
+				return nil, &TimeoutError{Err: fmt.Errorf("incomplete frame: received '%v' bytes", len(frame))}
+			}
+			return nil, &TimeoutError{Err: fmt.Errorf("incomplete frame: received '%v' of '%v' bytes", len(frame), expected)}
+		}
+		if gap {
+			return nil, framingError(RTU, "inter-character gap exceeds '%v'", timing.InterCharTimeout)
+		}
+		if len(frame) == 0 {
+			if err = port.SetReadTimeout(timing.InterCharTimeout); err != nil {
+				return nil, err
+			}
+		}
+		frame = append(frame, temp[:n]...)
+		if len(frame) < 2 {
+			continue
+		}
+		if !silence && !responsePredictable(aduRequest.GetPDU(), frame[1]) {
+			silence = true
 		}
-		buf.Write(temp[:n])
-		if buf.Len() > rtuMinSize {
-			break
+		if silence {
+			if len(frame) == rtuMaxSize {
+				return frame, nil
+			}
+			continue
 		}
-		time.Sleep(sleep)
+		length, ok := rtuFrameLength(aduRequest, frame)
+		if !ok {
+			continue
+		}
+		if length > rtuMaxSize {
+			return nil, framingError(RTU, "response frame length '%v' exceeds the maximum limit of '%v'", length, rtuMaxSize)
+		}
+		expected = length
+		if len(frame) >= length {
+			return frame[:length], nil
+		}
+	}
+}
+
+// readAsciiFrame 读取一帧ASCII响应,跳过帧起始符':'之前的干扰字符,读取至CR LF,收到新的起始符时重新开始
+// 起始符到达前按响应超时等待,之后字符间隔超过 AsciiInterCharTimeout 时返回 TimeoutError
+func (t *SerialPortTransporter) readAsciiFrame(port serial.Port) (frame []byte, err error) {
+	timing := t.EffectiveTiming()
+	if err = port.SetReadTimeout(timing.ResponseTimeout); err != nil {
+		return
 	}
-	aduResponse = buf.Bytes()
+	maxSize := asciiMaxSize + len(asciiStart) + len(asciiEnd)
+	frame = make([]byte, 0, maxSize)
+	temp := make([]byte, maxSize)
+	for {
+		var n int
+		if n, err = port.Read(temp); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			if len(frame) == 0 {
+				return nil, &TimeoutError{}
+			}
+			return nil, &TimeoutError{Err: fmt.Errorf("incomplete frame: received '%v' bytes", len(frame))}
+		}
+		for _, b := range temp[:n] {
+			if b == asciiStart[0] {
+				// 帧内再次出现起始符时丢弃之前的不完整帧,从新的起始符开始
+				if len(frame) == 0 {
+					if err = port.SetReadTimeout(timing.AsciiInterCharTimeout); err != nil {
+						return nil, err
+					}
+				}
+				frame = frame[:0]
+			} else if len(frame) == 0 {
+				continue
+			}
+			frame = append(frame, b)
+			if bytes.HasSuffix(frame, []byte(asciiEnd)) {
+				return frame, nil
+			}
+			if len(frame) == maxSize {
+				return nil, framingError(ASCII, "response frame exceeds the maximum limit of '%v'", maxSize)
+			}
+		}
+	}
+}
+
+// Broadcast 发送广播请求,不等待响应
+// 转换延时从请求发送完毕开始计算,期间其他请求需等待
+func (t *SerialPortTransporter) Broadcast(ctx context.Context, aduRequest ApplicationDataUnit) (err error) {
+	if err = t.mu.Lock(ctx); err != nil {
+		return
+	}
+	defer t.mu.Unlock()
+	if err = waitTurnaround(ctx, t.idle); err != nil {
+		return
+	}
+	if !t.Connected() {
+		if err = t.open(); err != nil {
+			return
+		}
+	}
+	data := aduRequest.GetData()
+	if _, err = t.port.Write(data); err != nil {
+		_ = t.disconnect(err)
+		return
+	}
+	timing := t.EffectiveTiming()
+	t.idle = time.Now().Add(timing.CharTime*time.Duration(len(data)) + timing.TurnaroundDelay)
 	return
 }
 func (t *SerialPortTransporter) Close() error {
-	t.mu.Lock()
+	_ = t.mu.Lock(context.Background())
 	defer t.mu.Unlock()
 	return t.close()
 }
 
 func (t *SerialPortTransporter) close() error {
+	return t.disconnect(nil)
+}
+
+// disconnect 关闭串口并通知 OnDisconnect,cause为关闭原因
+func (t *SerialPortTransporter) disconnect(cause error) error {
 	if !t.Connected() {
 		return nil
 	}
 	err := t.port.Close()
 	t.port = nil
+	t.monitor.disconnected(cause, t.OnDisconnect)
 	return err
 }
 
-func (t *SerialPortTransporter) calculateDelay(aduRequest ApplicationDataUnit) time.Duration {
-	baudRate := t.BaudRate
-	if baudRate == 0 {
-		baudRate = defaultBaudRate
-	}
-	bitDelay := time.Second.Milliseconds() / int64(baudRate)
-	length := 0
-	switch aduRequest.GetFunctionCode() {
-	case FuncCodeReadDiscreteInputs,
-		FuncCodeReadCoils:
-		count := aduRequest.Length()
-		length += 1 + count/8
-		if count%8 != 0 {
-			length++
-		}
-	case FuncCodeReadInputRegisters,
-		FuncCodeReadHoldingRegisters,
-		FuncCodeReadWriteMultipleRegisters:
-		count := aduRequest.Length()
-		length += 1 + count*2
-	case FuncCodeWriteSingleCoil,
-		FuncCodeWriteMultipleCoils,
-		FuncCodeWriteSingleRegister,
-		FuncCodeWriteMultipleRegisters:
-		length += 4
-	}
-	byteDelay := bitDelay * serialByteLen
-	return time.Duration(rtuMinByteLen*byteDelay + int64(length)*byteDelay)
-}
-
 func NewSerialTransporter(portName string) (t *SerialPortTransporter) {
 	t = &SerialPortTransporter{
 		PortName: portName,
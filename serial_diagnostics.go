@@ -0,0 +1,219 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// 诊断子功能码
+const (
+	// DiagReturnQueryData 回送查询数据
+	DiagReturnQueryData = 0x00
+	// DiagRestartCommunications 重启通信
+	DiagRestartCommunications = 0x01
+	// DiagReturnDiagnosticRegister 返回诊断寄存器
+	DiagReturnDiagnosticRegister = 0x02
+	// DiagChangeASCIIDelimiter 修改ASCII输入分隔符
+	DiagChangeASCIIDelimiter = 0x03
+	// DiagForceListenOnlyMode 强制只听模式
+	DiagForceListenOnlyMode = 0x04
+	// DiagClearCounters 清除计数器及诊断寄存器
+	DiagClearCounters = 0x0A
+	// DiagBusMessageCount 总线报文计数
+	DiagBusMessageCount = 0x0B
+	// DiagBusCommunicationErrorCount 总线通信错误(CRC错误)计数
+	DiagBusCommunicationErrorCount = 0x0C
+	// DiagBusExceptionErrorCount 总线异常响应计数
+	DiagBusExceptionErrorCount = 0x0D
+	// DiagServerMessageCount 从站报文计数
+	DiagServerMessageCount = 0x0E
+	// DiagServerNoResponseCount 从站无响应计数
+	DiagServerNoResponseCount = 0x0F
+	// DiagServerNAKCount 从站否认计数
+	DiagServerNAKCount = 0x10
+	// DiagServerBusyCount 从站忙计数
+	DiagServerBusyCount = 0x11
+	// DiagBusCharacterOverrunCount 总线字符溢出计数
+	DiagBusCharacterOverrunCount = 0x12
+	// DiagClearOverrunCounter 清除溢出计数器及标志
+	DiagClearOverrunCounter = 0x14
+)
+
+// CommEventCounter 通信事件计数器
+type CommEventCounter struct {
+	// Busy 从站正在处理先前的程序命令
+	Busy bool
+	// EventCount 成功完成的报文计数
+	EventCount uint16
+}
+
+// CommEventLog 通信事件记录
+type CommEventLog struct {
+	// Busy 从站正在处理先前的程序命令
+	Busy bool
+	// EventCount 成功完成的报文计数
+	EventCount uint16
+	// MessageCount 总线报文计数
+	MessageCount uint16
+	// Events 事件字节,最近的事件在前,最多64个
+	Events []byte
+}
+
+// ServerIDReport 报告从站ID的响应
+// 从站ID长度由设备定义,此处按常见实现取首字节为从站ID、次字节为运行指示状态
+type ServerIDReport struct {
+	ServerID byte
+	// RunIndicator 运行指示状态
+	RunIndicator bool
+	// AdditionalData 设备附加数据
+	AdditionalData []byte
+	// Data 字节数之后的原始数据
+	Data []byte
+}
+
+func (c *ModbusClient) ReadExceptionStatus() (status byte, err error) {
+	_, results, err := c.invoke(protocolDataUnit{functionCode: FuncCodeReadExceptionStatus})
+	if err != nil {
+		return
+	}
+	data := results.GetPDU().GetData()
+	if len(data) != 1 {
+		err = framingError(results.GetMode(), "exception status response size '%v' does not match '%v'", len(data), 1)
+		return
+	}
+	return data[0], nil
+}
+func (c *ModbusClient) Diagnostics(subFunction uint16, data []byte) (results []byte, err error) {
+	pdu := diagnosticsPDU(subFunction, data)
+	_, response, err := c.invoke(pdu)
+	if err != nil {
+		return
+	}
+	responseData := response.GetPDU().GetData()
+	if len(responseData) < 2 {
+		err = framingError(response.GetMode(), "diagnostics response size '%v' less than minimum limit of '%v'", len(responseData), 2)
+		return
+	}
+	if sub := binary.BigEndian.Uint16(responseData); sub != subFunction {
+		err = fmt.Errorf("modbus: response diagnostics sub-function '%v' does not match request '%v'", sub, subFunction)
+		return
+	}
+	return responseData[2:], nil
+}
+func (c *ModbusClient) ReturnQueryData(data []byte) (err error) {
+	results, err := c.Diagnostics(DiagReturnQueryData, data)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(results, data) {
+		err = fmt.Errorf("modbus: response query data '%v' does not match request '%v'", hex.EncodeToString(results), hex.EncodeToString(data))
+	}
+	return
+}
+func (c *ModbusClient) RestartCommunications(clearLog bool) (err error) {
+	var value uint16 = 0x0000
+	if clearLog {
+		value = 0xFF00
+	}
+	_, err = c.Diagnostics(DiagRestartCommunications, dataBlock(value))
+	return
+}
+func (c *ModbusClient) ReadDiagnosticRegister() (value uint16, err error) {
+	return c.diagnosticsValue(DiagReturnDiagnosticRegister)
+}
+func (c *ModbusClient) ForceListenOnlyMode() (err error) {
+	request, err := c.packager.Encode(diagnosticsPDU(DiagForceListenOnlyMode, dataBlock(0)))
+	if err != nil {
+		return
+	}
+	return c.post(request)
+}
+func (c *ModbusClient) ClearCounters() (err error) {
+	_, err = c.Diagnostics(DiagClearCounters, dataBlock(0))
+	return
+}
+func (c *ModbusClient) ReadDiagnosticCounter(subFunction uint16) (count uint16, err error) {
+	if subFunction < DiagBusMessageCount || subFunction > DiagBusCharacterOverrunCount {
+		err = fmt.Errorf("modbus: diagnostics counter sub-function '%v' must be between '%v' and '%v'", subFunction, DiagBusMessageCount, DiagBusCharacterOverrunCount)
+		return
+	}
+	return c.diagnosticsValue(subFunction)
+}
+
+// diagnosticsValue 发送数据为0的诊断请求,返回响应中的16位值
+func (c *ModbusClient) diagnosticsValue(subFunction uint16) (value uint16, err error) {
+	results, err := c.Diagnostics(subFunction, dataBlock(0))
+	if err != nil {
+		return
+	}
+	if len(results) != 2 {
+		err = fmt.Errorf("modbus: diagnostics sub-function '%v' response data size '%v' does not match '%v'", subFunction, len(results), 2)
+		return
+	}
+	return binary.BigEndian.Uint16(results), nil
+}
+func (c *ModbusClient) GetCommEventCounter() (counter *CommEventCounter, err error) {
+	_, results, err := c.invoke(protocolDataUnit{functionCode: FuncCodeGetCommEventCounter})
+	if err != nil {
+		return
+	}
+	data := results.GetPDU().GetData()
+	if len(data) != 4 {
+		err = framingError(results.GetMode(), "comm event counter response size '%v' does not match '%v'", len(data), 4)
+		return
+	}
+	counter = &CommEventCounter{
+		Busy:       binary.BigEndian.Uint16(data) == 0xFFFF,
+		EventCount: binary.BigEndian.Uint16(data[2:]),
+	}
+	return
+}
+func (c *ModbusClient) GetCommEventLog() (log *CommEventLog, err error) {
+	_, results, err := c.invoke(protocolDataUnit{functionCode: FuncCodeGetCommEventLog})
+	if err != nil {
+		return
+	}
+	pdu := results.GetPDU()
+	data := pdu.GetData()
+	if pdu.Length() != len(data) || len(data) < 6 || len(data) > 6+64 {
+		err = framingError(results.GetMode(), "comm event log byte count '%v' does not match data length '%v'", pdu.Length(), len(data))
+		return
+	}
+	log = &CommEventLog{
+		Busy:         binary.BigEndian.Uint16(data) == 0xFFFF,
+		EventCount:   binary.BigEndian.Uint16(data[2:]),
+		MessageCount: binary.BigEndian.Uint16(data[4:]),
+		Events:       data[6:],
+	}
+	return
+}
+func (c *ModbusClient) ReportServerID() (report *ServerIDReport, err error) {
+	_, results, err := c.invoke(protocolDataUnit{functionCode: FuncCodeReportServerID})
+	if err != nil {
+		return
+	}
+	pdu := results.GetPDU()
+	data := pdu.GetData()
+	if pdu.Length() != len(data) || len(data) < 2 {
+		err = framingError(results.GetMode(), "server id byte count '%v' does not match data length '%v'", pdu.Length(), len(data))
+		return
+	}
+	report = &ServerIDReport{
+		ServerID:       data[0],
+		RunIndicator:   data[1] == 0xFF,
+		AdditionalData: data[2:],
+		Data:           data,
+	}
+	return
+}
+
+func diagnosticsPDU(subFunction uint16, data []byte) protocolDataUnit {
+	pduData := append(dataBlock(subFunction), data...)
+	return protocolDataUnit{
+		functionCode: FuncCodeDiagnostics,
+		data:         pduData,
+		length:       len(pduData),
+	}
+}
@@ -0,0 +1,100 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+)
+
+func newDiagnosticsClient() (c *ModbusClient, port *testPort) {
+	port = newTestPort()
+	port.timeout = 20 * time.Millisecond
+	st := &SerialPortTransporter{port: port}
+	st.BaudRate = 115200
+	return NewModbusClient(NewRtuPackager(1), st), port
+}
+
+func TestReadExceptionStatus(t *testing.T) {
+	c, port := newDiagnosticsClient()
+	port.reads <- rtuFrame(1, FuncCodeReadExceptionStatus, []byte{0x6D})
+	status, err := c.ReadExceptionStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0x6D {
+		t.Fatalf("unexpected status %X", status)
+	}
+	if frame := <-port.written; len(frame) != 4 || frame[1] != FuncCodeReadExceptionStatus {
+		t.Fatalf("unexpected request %X", frame)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c, port := newDiagnosticsClient()
+	port.reads <- rtuFrame(1, FuncCodeDiagnostics, []byte{0, DiagReturnQueryData, 0xA5, 0x37})
+	if err := c.ReturnQueryData([]byte{0xA5, 0x37}); err != nil {
+		t.Fatal(err)
+	}
+	port.reads <- rtuFrame(1, FuncCodeDiagnostics, []byte{0, DiagReturnQueryData, 0xA5, 0x38})
+	if err := c.ReturnQueryData([]byte{0xA5, 0x37}); err == nil {
+		t.Fatal("expected query data mismatch")
+	}
+	port.reads <- rtuFrame(1, FuncCodeDiagnostics, []byte{0, DiagRestartCommunications, 0xFF, 0})
+	if err := c.RestartCommunications(true); err != nil {
+		t.Fatal(err)
+	}
+	port.reads <- rtuFrame(1, FuncCodeDiagnostics, []byte{0, DiagBusCommunicationErrorCount, 0, 3})
+	count, err := c.ReadDiagnosticCounter(DiagBusCommunicationErrorCount)
+	if err != nil || count != 3 {
+		t.Fatalf("unexpected counter %v %v", count, err)
+	}
+	port.reads <- rtuFrame(1, FuncCodeDiagnostics, []byte{0, DiagBusMessageCount, 0, 3})
+	if _, err = c.ReadDiagnosticCounter(DiagServerNAKCount); err == nil {
+		t.Fatal("expected sub-function mismatch")
+	}
+	for len(port.written) > 0 {
+		<-port.written
+	}
+	if err = c.ForceListenOnlyMode(); err != nil {
+		t.Fatal(err)
+	}
+	if frame := <-port.written; frame[0] != 1 || frame[1] != FuncCodeDiagnostics || frame[3] != DiagForceListenOnlyMode {
+		t.Fatalf("unexpected request %X", frame)
+	}
+}
+
+func TestCommEvents(t *testing.T) {
+	c, port := newDiagnosticsClient()
+	port.reads <- rtuFrame(1, FuncCodeGetCommEventCounter, []byte{0xFF, 0xFF, 0x01, 0x08})
+	counter, err := c.GetCommEventCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !counter.Busy || counter.EventCount != 0x0108 {
+		t.Fatalf("unexpected counter %+v", counter)
+	}
+	port.reads <- rtuFrame(1, FuncCodeGetCommEventLog, []byte{8, 0, 0, 0x01, 0x08, 0x01, 0x21, 0x20, 0x00})
+	log, err := c.GetCommEventLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Busy || log.EventCount != 0x0108 || log.MessageCount != 0x0121 || len(log.Events) != 2 || log.Events[0] != 0x20 {
+		t.Fatalf("unexpected log %+v", log)
+	}
+}
+
+func TestReportServerID(t *testing.T) {
+	c, port := newDiagnosticsClient()
+	port.reads <- rtuFrame(1, FuncCodeReportServerID, []byte{4, 0x2A, 0xFF, 'v', '1'})
+	report, err := c.ReportServerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ServerID != 0x2A || !report.RunIndicator || string(report.AdditionalData) != "v1" || len(report.Data) != 4 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	pk := NewAsciiPackager(1)
+	results, err := pk.Decode(asciiFrame(1, FuncCodeReportServerID, []byte{4, 0x2A, 0x00, 'v', '1'}))
+	if err != nil || results.GetPDU().Length() != 4 || len(results.GetPDU().GetData()) != 4 {
+		t.Fatalf("unexpected ascii decode %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"go.bug.st/serial"
+)
+
+// SerialServer modbus串口服务端(从站),复用 SerialPortTransporter 的串口配置
+type SerialServer struct {
+	Transporter *SerialPortTransporter
+	SlaveID     byte
+	Handler     Handler
+	mode        ModbusMode
+	mu          sync.Mutex
+	closed      bool
+}
+
+// Serve 打开串口并循环处理请求,直到串口出错或服务端关闭
+func (s *SerialServer) Serve() error {
+	port, err := s.open()
+	if err != nil {
+		return err
+	}
+	if s.mode == ASCII {
+		err = s.serveAscii(port)
+	} else {
+		err = s.serveRtu(port)
+	}
+	if s.isClosed() {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// serveRtu 以3.5个字符的帧间隔判断帧结束,帧内字符间隔超过1.5个字符时间时丢弃整帧
+func (s *SerialServer) serveRtu(port serial.Port) error {
+	timing := s.Transporter.EffectiveTiming()
+	buf := bytes.NewBuffer([]byte{})
+	temp := make([]byte, rtuMaxSize)
+	// gap 已超过t1.5未收到数据,invalid 当前帧无效,丢弃数据直到帧间隔
+	gap, invalid := false, false
+	for {
+		n, err := port.Read(temp)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if gap {
+				invalid, gap = true, false
+				if err = port.SetReadTimeout(timing.InterCharTimeout); err != nil {
+					return err
+				}
+			}
+			if invalid {
+				continue
+			}
+			buf.Write(temp[:n])
+			if buf.Len() > rtuMaxSize {
+				// 超长帧直接丢弃,等待下一次帧间隔
+				buf.Reset()
+				invalid = true
+			}
+			continue
+		}
+		if buf.Len() == 0 && !invalid {
+			continue
+		}
+		if !gap && timing.frameGap() > 0 {
+			gap = true
+			if err = port.SetReadTimeout(timing.frameGap()); err != nil {
+				return err
+			}
+			continue
+		}
+		// 超过3.5个字符时间未收到数据,视为一帧结束
+		if gap {
+			gap = false
+			if err = port.SetReadTimeout(timing.InterCharTimeout); err != nil {
+				return err
+			}
+		}
+		if invalid {
+			invalid = false
+			buf.Reset()
+			continue
+		}
+		response := s.handleRtu(buf.Bytes())
+		buf.Reset()
+		if response == nil {
+			continue
+		}
+		if _, err = port.Write(response); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *SerialServer) serveAscii(port serial.Port) error {
+	buf := bytes.NewBuffer([]byte{})
+	temp := make([]byte, asciiMaxSize)
+	for {
+		n, err := port.Read(temp)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// 字符间隔超时,丢弃未完成的帧
+			buf.Reset()
+			continue
+		}
+		for _, b := range temp[:n] {
+			if b == asciiStart[0] {
+				buf.Reset()
+			} else if buf.Len() == 0 {
+				// 跳过帧起始符之前的干扰字符
+				continue
+			}
+			buf.WriteByte(b)
+			if buf.Len() > asciiMaxSize+len(asciiStart)+len(asciiEnd) {
+				buf.Reset()
+				continue
+			}
+			if !bytes.HasSuffix(buf.Bytes(), []byte(asciiEnd)) {
+				continue
+			}
+			response := s.handleAscii(buf.Bytes())
+			buf.Reset()
+			if response == nil {
+				continue
+			}
+			if _, err = port.Write(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *SerialServer) open() (port serial.Port, err error) {
+	t := s.Transporter
+	if s.isClosed() {
+		return nil, ErrServerClosed
+	}
+	_ = t.mu.Lock(context.Background())
+	defer t.mu.Unlock()
+	if !t.Connected() {
+		if err = t.open(); err != nil {
+			return
+		}
+	}
+	port = t.port
+	if s.mode == RTU {
+		err = port.SetReadTimeout(t.EffectiveTiming().InterCharTimeout)
+	} else {
+		err = port.SetReadTimeout(t.EffectiveTiming().AsciiInterCharTimeout)
+	}
+	return
+}
+
+// handleRtu 处理一帧RTU请求,返回需要写回的响应,返回nil时不响应
+func (s *SerialServer) handleRtu(frame []byte) []byte {
+	_, request, err := (&rtuPackager{}).DecodeRequest(frame)
+	if request == nil {
+		return nil
+	}
+	return s.handle(request, err)
+}
+
+// handleAscii 处理一帧ASCII请求,返回需要写回的响应,返回nil时不响应
+func (s *SerialServer) handleAscii(frame []byte) []byte {
+	_, request, err := (&asciiPackager{}).DecodeRequest(frame)
+	if request == nil {
+		return nil
+	}
+	return s.handle(request, err)
+}
+
+func (s *SerialServer) handle(request *Request, parseErr error) []byte {
+	slaveID := request.SlaveID
+	if slaveID != 0 && slaveID != s.SlaveID {
+		return nil
+	}
+	response := serve(s.Handler, s.SlaveID, request, parseErr)
+	// 广播请求不响应
+	if slaveID == 0 {
+		return nil
+	}
+	var packager Packager = &rtuPackager{slaveID: s.SlaveID}
+	if s.mode == ASCII {
+		packager = &asciiPackager{slaveID: s.SlaveID}
+	}
+	adu, err := packager.Encode(response)
+	if err != nil {
+		return nil
+	}
+	return adu.GetData()
+}
+
+// Close 关闭服务端及串口
+func (s *SerialServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.Transporter.Close()
+}
+
+func (s *SerialServer) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// NewRtuSerialServer 创建RTU串口从站
+func NewRtuSerialServer(transporter *SerialPortTransporter, slaveID byte, handler Handler) (s *SerialServer, err error) {
+	return newSerialServer(transporter, slaveID, handler, RTU)
+}
+
+// NewAsciiSerialServer 创建ASCII串口从站
+func NewAsciiSerialServer(transporter *SerialPortTransporter, slaveID byte, handler Handler) (s *SerialServer, err error) {
+	return newSerialServer(transporter, slaveID, handler, ASCII)
+}
+
+func newSerialServer(transporter *SerialPortTransporter, slaveID byte, handler Handler, mode ModbusMode) (s *SerialServer, err error) {
+	if slaveID < 1 || slaveID > 247 {
+		err = fmt.Errorf("modbus: slaveId '%v' must be between '%v' and '%v'", slaveID, 1, 247)
+		return
+	}
+	s = &SerialServer{
+		Transporter: transporter,
+		SlaveID:     slaveID,
+		Handler:     handler,
+		mode:        mode,
+	}
+	return
+}
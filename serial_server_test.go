@@ -0,0 +1,178 @@
+package modbus
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// testPort 模拟串口,reads中的每个元素作为一次读取返回
+type testPort struct {
+	reads   chan []byte
+	written chan []byte
+	timeout time.Duration
+	once    sync.Once
+	done    chan struct{}
+}
+
+func newTestPort() *testPort {
+	return &testPort{
+		reads:   make(chan []byte, 16),
+		written: make(chan []byte, 16),
+		timeout: time.Second,
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *testPort) SetMode(mode *serial.Mode) error { return nil }
+func (p *testPort) Read(b []byte) (int, error) {
+	select {
+	case <-p.done:
+		return 0, errors.New("port closed")
+	default:
+	}
+	select {
+	case data := <-p.reads:
+		return copy(b, data), nil
+	case <-time.After(p.timeout):
+		return 0, nil
+	case <-p.done:
+		return 0, errors.New("port closed")
+	}
+}
+func (p *testPort) Write(b []byte) (int, error) {
+	p.written <- append([]byte(nil), b...)
+	return len(b), nil
+}
+func (p *testPort) ResetInputBuffer() error                              { return nil }
+func (p *testPort) ResetOutputBuffer() error                             { return nil }
+func (p *testPort) SetDTR(dtr bool) error                                { return nil }
+func (p *testPort) SetRTS(rts bool) error                                { return nil }
+func (p *testPort) GetModemStatusBits() (*serial.ModemStatusBits, error) { return nil, nil }
+func (p *testPort) SetReadTimeout(t time.Duration) error {
+	p.timeout = t
+	return nil
+}
+func (p *testPort) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+func (p *testPort) Break(time.Duration) error { return nil }
+
+func rtuFrame(slaveID byte, functionCode byte, data []byte) []byte {
+	adu, _ := (&rtuPackager{slaveID: slaveID}).Encode(protocolDataUnit{functionCode: functionCode, data: data})
+	return adu.GetData()
+}
+
+func startTestSerialServer(t *testing.T, s *SerialServer, port *testPort) {
+	s.Transporter.port = port
+	go func() { _ = s.Serve() }()
+	t.Cleanup(func() { _ = s.Close() })
+}
+
+func TestRtuSerialServer(t *testing.T) {
+	port := newTestPort()
+	store := NewMemoryDataStore(0, 0, 0, 10)
+	s, err := NewRtuSerialServer(NewSerialTransporter("COM1"), 3, NewStoreHandler(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTestSerialServer(t, s, port)
+	// 请求分两次到达,中间间隔小于3.5字符时间
+	frame := rtuFrame(3, FuncCodeWriteSingleRegister, dataBlock(1, 0x1234))
+	port.reads <- frame[:3]
+	port.reads <- frame[3:]
+	select {
+	case response := <-port.written:
+		if string(response) != string(frame) {
+			t.Fatalf("unexpected response % x", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response")
+	}
+	// 其它从站地址及广播请求不响应
+	port.reads <- rtuFrame(4, FuncCodeWriteSingleRegister, dataBlock(2, 1))
+	time.Sleep(20 * time.Millisecond)
+	port.reads <- rtuFrame(0, FuncCodeWriteSingleRegister, dataBlock(3, 0x5678))
+	time.Sleep(20 * time.Millisecond)
+	port.reads <- rtuFrame(3, FuncCodeReadHoldingRegisters, dataBlock(1, 3))
+	select {
+	case response := <-port.written:
+		expect := rtuFrame(3, FuncCodeReadHoldingRegisters, dataBlockSuffix(dataBlock(0x1234, 0, 0x5678)))
+		if string(response) != string(expect) {
+			t.Fatalf("unexpected response % x", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response")
+	}
+}
+
+func TestRtuSerialServerInterCharTimeout(t *testing.T) {
+	port := newTestPort()
+	st := NewSerialTransporter("COM1")
+	st.Timing = SerialTiming{InterCharTimeout: 10 * time.Millisecond, InterFrameDelay: 50 * time.Millisecond}
+	s, err := NewRtuSerialServer(st, 3, NewStoreHandler(NewMemoryDataStore(0, 0, 0, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTestSerialServer(t, s, port)
+	// 帧内字符间隔超过t1.5,丢弃整帧
+	frame := rtuFrame(3, FuncCodeWriteSingleRegister, dataBlock(1, 0x1234))
+	port.reads <- frame[:3]
+	time.Sleep(30 * time.Millisecond)
+	port.reads <- frame[3:]
+	select {
+	case response := <-port.written:
+		t.Fatalf("unexpected response % x", response)
+	case <-time.After(100 * time.Millisecond):
+	}
+	port.reads <- frame
+	select {
+	case response := <-port.written:
+		if string(response) != string(frame) {
+			t.Fatalf("unexpected response % x", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response")
+	}
+}
+
+func asciiFrame(slaveID byte, functionCode byte, data []byte) []byte {
+	adu, _ := (&asciiPackager{slaveID: slaveID}).Encode(protocolDataUnit{functionCode: functionCode, data: data})
+	return adu.GetData()
+}
+
+func TestAsciiSerialServer(t *testing.T) {
+	port := newTestPort()
+	store := NewMemoryDataStore(16, 0, 0, 0)
+	s, err := NewAsciiSerialServer(NewSerialTransporter("COM1"), 1, NewStoreHandler(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTestSerialServer(t, s, port)
+	frame := asciiFrame(1, FuncCodeWriteSingleCoil, dataBlock(2, 0xFF00))
+	// 帧前的干扰字符被跳过,帧可分多次到达
+	port.reads <- append([]byte{0x00, 'x'}, frame[:5]...)
+	port.reads <- frame[5:]
+	select {
+	case response := <-port.written:
+		if string(response) != string(frame) {
+			t.Fatalf("unexpected response %q", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response")
+	}
+	port.reads <- asciiFrame(1, FuncCodeReadCoils, dataBlock(0, 4))
+	select {
+	case response := <-port.written:
+		expect := asciiFrame(1, FuncCodeReadCoils, []byte{1, 0x04})
+		if string(response) != string(expect) {
+			t.Fatalf("unexpected response %q", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response")
+	}
+}
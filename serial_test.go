@@ -0,0 +1,170 @@
+package modbus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSerialTestClient(readTimeout time.Duration) (c *ModbusClient, port *testPort) {
+	port = newTestPort()
+	st := &SerialPortTransporter{port: port, ReadTimeout: readTimeout}
+	st.BaudRate = 115200
+	return NewModbusClient(NewRtuPackager(1), st), port
+}
+
+func TestSerialReadLongFrame(t *testing.T) {
+	c, port := newSerialTestClient(100 * time.Millisecond)
+	value := make([]uint16, 125)
+	for i := range value {
+		value[i] = uint16(i)
+	}
+	frame := rtuFrame(1, FuncCodeReadHoldingRegisters, append([]byte{250}, dataBlock(value...)...))
+	// 分多次到达,字符间隔小于t1.5
+	for i := 0; i < len(frame); i += 64 {
+		end := i + 64
+		if end > len(frame) {
+			end = len(frame)
+		}
+		port.reads <- frame[i:end]
+	}
+	results, err := c.ReadHoldingRegisterValues(0, 125)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[124] != 124 {
+		t.Fatalf("unexpected registers %v", results)
+	}
+}
+
+func TestSerialReadIncompleteFrame(t *testing.T) {
+	c, port := newSerialTestClient(30 * time.Millisecond)
+	frame := rtuFrame(1, FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2})
+	port.reads <- frame[:6]
+	_, err := c.ReadHoldingRegisterValues(0, 2)
+	e, ok := err.(*TimeoutError)
+	if !ok || e.Err == nil || !strings.Contains(e.Err.Error(), "'6' of '9'") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 无响应
+	if _, err = c.ReadHoldingRegisterValues(0, 2); err == nil {
+		t.Fatal("expected timeout")
+	} else if e, ok = err.(*TimeoutError); !ok || e.Err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSerialReadUnknownFunction(t *testing.T) {
+	c, port := newSerialTestClient(100 * time.Millisecond)
+	frame := rtuFrame(1, 100, []byte{1, 2, 3, 4, 5, 6})
+	port.reads <- frame[:3]
+	port.reads <- frame[3:]
+	_, results, err := c.SendRaw(100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := results.GetPDU().GetData(); len(data) != 6 || data[5] != 6 {
+		t.Fatalf("unexpected data %X", data)
+	}
+}
+
+func TestSerialReadInterCharTimeout(t *testing.T) {
+	c, port := newSerialTestClient(100 * time.Millisecond)
+	c.transporter.(*SerialPortTransporter).Timing = SerialTiming{InterCharTimeout: 10 * time.Millisecond, InterFrameDelay: 50 * time.Millisecond}
+	frame := rtuFrame(1, FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2})
+	// 帧内字符间隔超过t1.5,丢弃整帧
+	go func() {
+		port.reads <- frame[:4]
+		time.Sleep(30 * time.Millisecond)
+		port.reads <- frame[4:]
+	}()
+	_, err := c.ReadHoldingRegisterValues(0, 2)
+	if e, ok := err.(*TimeoutError); !ok || e.Err == nil || !strings.Contains(e.Err.Error(), "'4' of '9'") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// 无法预测长度时,t1.5至t3.5之间收到数据视为帧错误
+	raw := rtuFrame(1, 100, []byte{1, 2, 3, 4})
+	go func() {
+		port.reads <- raw[:3]
+		time.Sleep(30 * time.Millisecond)
+		port.reads <- raw[3:]
+	}()
+	if _, _, err = c.SendRaw(100, nil); !errors.As(err, new(*FramingError)) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	port.reads <- raw
+	if _, results, err := c.SendRaw(100, nil); err != nil || len(results.GetPDU().GetData()) != 4 {
+		t.Fatalf("unexpected response %v %v", results, err)
+	}
+}
+
+func newAsciiSerialTestClient(timing SerialTiming) (c *ModbusClient, port *testPort) {
+	port = newTestPort()
+	st := &SerialPortTransporter{port: port, Timing: timing}
+	return NewModbusClient(NewAsciiPackager(1), st), port
+}
+
+func TestSerialReadAsciiFrame(t *testing.T) {
+	c, port := newAsciiSerialTestClient(SerialTiming{ResponseTimeout: 100 * time.Millisecond, AsciiInterCharTimeout: 100 * time.Millisecond})
+	value := make([]uint16, 60)
+	for i := range value {
+		value[i] = uint16(i)
+	}
+	frame := asciiFrame(1, FuncCodeReadHoldingRegisters, append([]byte{120}, dataBlock(value...)...))
+	go func() {
+		// 起始符前的干扰字符
+		port.reads <- []byte{0x00, '\n'}
+		for i := 0; i < len(frame); i += 16 {
+			end := i + 16
+			if end > len(frame) {
+				end = len(frame)
+			}
+			port.reads <- frame[i:end]
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	results, err := c.ReadHoldingRegisterValues(0, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[59] != 59 {
+		t.Fatalf("unexpected registers %v", results)
+	}
+}
+
+func TestSerialReadAsciiIncompleteFrame(t *testing.T) {
+	c, port := newAsciiSerialTestClient(SerialTiming{ResponseTimeout: 100 * time.Millisecond, AsciiInterCharTimeout: 20 * time.Millisecond})
+	frame := asciiFrame(1, FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2})
+	port.reads <- frame[:len(frame)-2]
+	_, err := c.ReadHoldingRegisterValues(0, 2)
+	e, ok := err.(*TimeoutError)
+	if !ok || e.Err == nil || !strings.Contains(e.Err.Error(), "incomplete frame") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSerialReadAsciiOversizeFrame(t *testing.T) {
+	c, port := newAsciiSerialTestClient(SerialTiming{ResponseTimeout: 100 * time.Millisecond, AsciiInterCharTimeout: 100 * time.Millisecond})
+	port.reads <- append([]byte(":"), []byte(strings.Repeat("0", asciiMaxSize+2))...)
+	_, err := c.ReadHoldingRegisterValues(0, 2)
+	if _, ok := err.(*FramingError); !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSerialReadAsciiRestartFrame(t *testing.T) {
+	c, port := newAsciiSerialTestClient(SerialTiming{ResponseTimeout: 100 * time.Millisecond, AsciiInterCharTimeout: 100 * time.Millisecond})
+	frame := asciiFrame(1, FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2})
+	// 不完整帧之后收到新的起始符,从新帧开始读取
+	port.reads <- append(frame[:7:7], frame...)
+	results, err := c.ReadHoldingRegisterValues(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0] != 1 || results[1] != 2 {
+		t.Fatalf("unexpected registers %v", results)
+	}
+}
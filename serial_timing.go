@@ -0,0 +1,110 @@
+package modbus
+
+import (
+	"time"
+
+	"go.bug.st/serial"
+)
+
+const (
+	// 波特率大于19200时的固定字符间隔超时与帧间隔
+	fixedInterCharTimeout = 750 * time.Microsecond
+	fixedInterFrameDelay  = 1750 * time.Microsecond
+	// ASCII模式字符间隔超时默认值
+	defaultAsciiInterCharTimeout = time.Second
+)
+
+// SerialTiming 串行链路时序参数,精确到微秒
+type SerialTiming struct {
+	// CharTime 单个字符的传输时间
+	CharTime time.Duration
+	// InterCharTimeout 字符间隔超时(t1.5),RTU帧内字符间隔超过该值时丢弃整帧
+	// USB转串口设备存在传输延迟时可适当增大
+	InterCharTimeout time.Duration
+	// InterFrameDelay 帧间隔(t3.5),从站以此判断帧结束
+	InterFrameDelay time.Duration
+	// AsciiInterCharTimeout ASCII模式字符间隔超时,默认1s
+	AsciiInterCharTimeout time.Duration
+	// ResponseTimeout 等待响应超时
+	ResponseTimeout time.Duration
+	// TurnaroundDelay 广播后到下一次请求的等待时间
+	TurnaroundDelay time.Duration
+}
+
+// characterHalfBits 单个字符的位数,包括起始位、数据位、校验位及停止位,以半位为单位以表示1.5个停止位
+func characterHalfBits(mode serial.Mode) int64 {
+	dataBits := int64(mode.DataBits)
+	if dataBits == 0 {
+		dataBits = 8
+	}
+	bits := 2 * (1 + dataBits)
+	if mode.Parity != serial.NoParity {
+		bits += 2
+	}
+	switch mode.StopBits {
+	case serial.OnePointFiveStopBits:
+		bits += 3
+	case serial.TwoStopBits:
+		bits += 4
+	default:
+		bits += 2
+	}
+	return bits
+}
+
+// NewSerialTiming 根据串口参数计算时序,未设置波特率时按9600计算
+// 波特率大于19200时t1.5、t3.5分别固定为750us、1750us,ASCII字符间隔超时及等待响应超时默认1s,广播转换延时默认100ms
+func NewSerialTiming(mode serial.Mode) (timing SerialTiming) {
+	baudRate := int64(mode.BaudRate)
+	if baudRate <= 0 {
+		baudRate = defaultBaudRate
+	}
+	// charsTime 传输指定半字符数所需时间,按微秒取整
+	halfBits := characterHalfBits(mode)
+	charsTime := func(halfChars int64) time.Duration {
+		return (time.Duration(halfChars*halfBits) * time.Second / time.Duration(4*baudRate)).Round(time.Microsecond)
+	}
+	timing.CharTime = charsTime(2)
+	if baudRate > 19200 {
+		timing.InterCharTimeout = fixedInterCharTimeout
+		timing.InterFrameDelay = fixedInterFrameDelay
+	} else {
+		timing.InterCharTimeout = charsTime(3)
+		timing.InterFrameDelay = charsTime(7)
+	}
+	timing.AsciiInterCharTimeout = defaultAsciiInterCharTimeout
+	timing.ResponseTimeout = defaultSerialReadTimeout
+	timing.TurnaroundDelay = defaultTurnaroundDelay
+	return
+}
+
+// EffectiveTiming 返回实际使用的时序:按 Mode 计算,ReadTimeout、TurnaroundDelay 及 Timing 中的非零值依次覆盖
+func (t *SerialPortTransporter) EffectiveTiming() (timing SerialTiming) {
+	timing = NewSerialTiming(t.Mode)
+	if t.ReadTimeout > 0 {
+		timing.ResponseTimeout = t.ReadTimeout
+	}
+	if t.TurnaroundDelay > 0 {
+		timing.TurnaroundDelay = t.TurnaroundDelay
+	}
+	override := func(value *time.Duration, v time.Duration) {
+		if v > 0 {
+			*value = v
+		}
+	}
+	override(&timing.CharTime, t.Timing.CharTime)
+	override(&timing.InterCharTimeout, t.Timing.InterCharTimeout)
+	override(&timing.InterFrameDelay, t.Timing.InterFrameDelay)
+	override(&timing.AsciiInterCharTimeout, t.Timing.AsciiInterCharTimeout)
+	override(&timing.ResponseTimeout, t.Timing.ResponseTimeout)
+	override(&timing.TurnaroundDelay, t.Timing.TurnaroundDelay)
+	return
+}
+
+// frameGap 字符间隔超过t1.5后到t3.5的剩余时间,期间收到的数据说明帧内出现非法间隔
+func (timing SerialTiming) frameGap() time.Duration {
+	if timing.InterFrameDelay > timing.InterCharTimeout {
+		return timing.InterFrameDelay - timing.InterCharTimeout
+	}
+	return 0
+}
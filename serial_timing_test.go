@@ -0,0 +1,56 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+func TestSerialTiming(t *testing.T) {
+	tests := []struct {
+		mode             serial.Mode
+		charTime         time.Duration
+		interCharTimeout time.Duration
+		interFrameDelay  time.Duration
+	}{
+		// 1+8+1+1 = 11位
+		{serial.Mode{BaudRate: 9600, DataBits: 8, Parity: serial.EvenParity}, 1146 * time.Microsecond, 1719 * time.Microsecond, 4010 * time.Microsecond},
+		{serial.Mode{BaudRate: 9600, DataBits: 8, StopBits: serial.TwoStopBits}, 1146 * time.Microsecond, 1719 * time.Microsecond, 4010 * time.Microsecond},
+		// 1+8+1 = 10位
+		{serial.Mode{BaudRate: 9600, DataBits: 8}, 1042 * time.Microsecond, 1563 * time.Microsecond, 3646 * time.Microsecond},
+		// 1+7+1+1.5 = 10.5位
+		{serial.Mode{BaudRate: 19200, DataBits: 7, Parity: serial.OddParity, StopBits: serial.OnePointFiveStopBits}, 547 * time.Microsecond, 820 * time.Microsecond, 1914 * time.Microsecond},
+		// 大于19200使用固定值
+		{serial.Mode{BaudRate: 115200, DataBits: 8, Parity: serial.EvenParity}, 95 * time.Microsecond, 750 * time.Microsecond, 1750 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		timing := NewSerialTiming(tt.mode)
+		if timing.CharTime != tt.charTime || timing.InterCharTimeout != tt.interCharTimeout || timing.InterFrameDelay != tt.interFrameDelay {
+			t.Errorf("%+v: got %+v", tt.mode, timing)
+		}
+		if timing.ResponseTimeout != defaultSerialReadTimeout || timing.TurnaroundDelay != defaultTurnaroundDelay {
+			t.Errorf("%+v: unexpected defaults %+v", tt.mode, timing)
+		}
+	}
+}
+
+func TestSerialEffectiveTiming(t *testing.T) {
+	st := NewSerialTransporter("COM1")
+	st.Mode = serial.Mode{BaudRate: 9600, DataBits: 8, Parity: serial.EvenParity}
+	st.ReadTimeout = 500 * time.Millisecond
+	st.TurnaroundDelay = 50 * time.Millisecond
+	st.Timing = SerialTiming{InterFrameDelay: 5 * time.Millisecond, TurnaroundDelay: 200 * time.Millisecond}
+	timing := st.EffectiveTiming()
+	want := SerialTiming{
+		CharTime:              1146 * time.Microsecond,
+		InterCharTimeout:      1719 * time.Microsecond,
+		InterFrameDelay:       5 * time.Millisecond,
+		AsciiInterCharTimeout: time.Second,
+		ResponseTimeout:       500 * time.Millisecond,
+		TurnaroundDelay:       200 * time.Millisecond,
+	}
+	if timing != want {
+		t.Fatalf("got %+v, want %+v", timing, want)
+	}
+}
@@ -0,0 +1,177 @@
+package modbus
+
+import (
+	"errors"
+)
+
+// Handler modbus服务端(从站)请求处理接口
+// 返回 Exception 类型的错误时以对应异常码响应,其它错误以从站设备故障响应
+type Handler interface {
+	// ReadCoils 读线圈 功能码:1
+	ReadCoils(slaveID byte, address, quantity uint16) (value []bool, err error)
+	// ReadDiscreteInputs 读离散量输入 功能码:2
+	ReadDiscreteInputs(slaveID byte, address, quantity uint16) (value []bool, err error)
+	// WriteSingleCoil 写单个线圈 功能码:5
+	WriteSingleCoil(slaveID byte, address uint16, value bool) (err error)
+	// WriteMultipleCoils 写多个线圈 功能码:15
+	WriteMultipleCoils(slaveID byte, address uint16, value []bool) (err error)
+	// ReadInputRegisters 读输入寄存器 功能码:4
+	ReadInputRegisters(slaveID byte, address, quantity uint16) (value []uint16, err error)
+	// ReadHoldingRegisters 读保持寄存器 功能码:3
+	ReadHoldingRegisters(slaveID byte, address, quantity uint16) (value []uint16, err error)
+	// WriteSingleRegister 写单个寄存器 功能码:6
+	WriteSingleRegister(slaveID byte, address, value uint16) (err error)
+	// WriteMultipleRegisters 写多个寄存器 功能码:16
+	WriteMultipleRegisters(slaveID byte, address uint16, value []uint16) (err error)
+	// ReadWriteMultipleRegisters 读/写多个寄存器,先写后读 功能码:23
+	ReadWriteMultipleRegisters(slaveID byte, readAddress, readQuantity, writeAddress uint16, value []uint16) (results []uint16, err error)
+}
+
+// MaskWriteRegisterHandler 支持屏蔽写寄存器的请求处理器,Handler 未实现时以非法功能响应
+type MaskWriteRegisterHandler interface {
+	// MaskWriteRegister 屏蔽写寄存器 功能码:22
+	MaskWriteRegister(slaveID byte, address, andMask, orMask uint16) (err error)
+}
+
+// ReadFIFOQueueHandler 支持读FIFO队列的请求处理器,Handler 未实现时以非法功能响应
+type ReadFIFOQueueHandler interface {
+	// ReadFIFOQueue 读FIFO队列,队列超过31个寄存器时应返回 ExceptionIllegalDataValue 功能码:24
+	ReadFIFOQueue(slaveID byte, address uint16) (value []uint16, err error)
+}
+
+// DeviceIdentificationHandler 支持读设备识别码的请求处理器,Handler 未实现时以非法功能响应
+type DeviceIdentificationHandler interface {
+	// DeviceIdentification 返回从站的设备识别码对象,对象0x00~0x02为必需对象 功能码:43/14
+	DeviceIdentification(slaveID byte) (objects map[byte]string, err error)
+}
+
+// serve 调用处理器处理已解析的请求,返回正常响应或异常响应
+// parseErr 为 ParseRequest 返回的错误,不为nil时直接以异常响应
+func serve(handler Handler, slaveID byte, request *Request, parseErr error) (response protocolDataUnit) {
+	functionCode := request.FunctionCode
+	if parseErr != nil {
+		return exceptionResponse(functionCode, parseErr)
+	}
+	data, err := dispatch(handler, slaveID, request)
+	if err != nil {
+		return exceptionResponse(functionCode, err)
+	}
+	response = protocolDataUnit{
+		functionCode: functionCode,
+		data:         data,
+		length:       len(data),
+	}
+	return
+}
+
+func dispatch(handler Handler, slaveID byte, request *Request) (results []byte, err error) {
+	switch request.FunctionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		var value []bool
+		if request.FunctionCode == FuncCodeReadCoils {
+			value, err = handler.ReadCoils(slaveID, request.Address, request.Quantity)
+		} else {
+			value, err = handler.ReadDiscreteInputs(slaveID, request.Address, request.Quantity)
+		}
+		if err != nil {
+			return
+		}
+		if len(value) != int(request.Quantity) {
+			return nil, ExceptionServerDeviceFailure
+		}
+		results = dataBlockSuffix(toBit(value))
+	case FuncCodeReadInputRegisters, FuncCodeReadHoldingRegisters:
+		var value []uint16
+		if request.FunctionCode == FuncCodeReadHoldingRegisters {
+			value, err = handler.ReadHoldingRegisters(slaveID, request.Address, request.Quantity)
+		} else {
+			value, err = handler.ReadInputRegisters(slaveID, request.Address, request.Quantity)
+		}
+		if err != nil {
+			return
+		}
+		if len(value) != int(request.Quantity) {
+			return nil, ExceptionServerDeviceFailure
+		}
+		results = dataBlockSuffix(dataBlock(value...))
+	case FuncCodeWriteSingleCoil:
+		if err = handler.WriteSingleCoil(slaveID, request.Address, request.Coils[0]); err != nil {
+			return
+		}
+		results = request.Data
+	case FuncCodeWriteSingleRegister:
+		if err = handler.WriteSingleRegister(slaveID, request.Address, request.Registers[0]); err != nil {
+			return
+		}
+		results = request.Data
+	case FuncCodeWriteMultipleCoils:
+		if err = handler.WriteMultipleCoils(slaveID, request.Address, request.Coils); err != nil {
+			return
+		}
+		results = dataBlock(request.Address, request.Quantity)
+	case FuncCodeWriteMultipleRegisters:
+		if err = handler.WriteMultipleRegisters(slaveID, request.Address, request.Registers); err != nil {
+			return
+		}
+		results = dataBlock(request.Address, request.Quantity)
+	case FuncCodeMaskWriteRegister:
+		h, ok := handler.(MaskWriteRegisterHandler)
+		if !ok {
+			return nil, ExceptionIllegalFunction
+		}
+		if err = h.MaskWriteRegister(slaveID, request.Address, request.AndMask, request.OrMask); err != nil {
+			return
+		}
+		results = request.Data
+	case FuncCodeReadFIFOQueue:
+		h, ok := handler.(ReadFIFOQueueHandler)
+		if !ok {
+			return nil, ExceptionIllegalFunction
+		}
+		var value []uint16
+		if value, err = h.ReadFIFOQueue(slaveID, request.Address); err != nil {
+			return
+		}
+		if len(value) > 31 {
+			return nil, ExceptionIllegalDataValue
+		}
+		count := len(value)
+		results = dataBlock(append([]uint16{uint16(2 + count*2), uint16(count)}, value...)...)
+	case FuncCodeEncapsulatedInterface:
+		h, ok := handler.(DeviceIdentificationHandler)
+		if !ok {
+			return nil, ExceptionIllegalFunction
+		}
+		var objects map[byte]string
+		if objects, err = h.DeviceIdentification(slaveID); err != nil {
+			return
+		}
+		results, err = deviceIdentificationResponse(objects, request.ReadDeviceIDCode, request.ObjectID)
+	case FuncCodeReadWriteMultipleRegisters:
+		var value []uint16
+		value, err = handler.ReadWriteMultipleRegisters(slaveID, request.Address, request.Quantity, request.WriteAddress, request.Registers)
+		if err != nil {
+			return
+		}
+		if len(value) != int(request.Quantity) {
+			return nil, ExceptionServerDeviceFailure
+		}
+		results = dataBlockSuffix(dataBlock(value...))
+	default:
+		err = ExceptionIllegalFunction
+	}
+	return
+}
+
+func exceptionResponse(functionCode byte, err error) protocolDataUnit {
+	exception := ExceptionServerDeviceFailure
+	var e Exception
+	if errors.As(err, &e) {
+		exception = e
+	}
+	return protocolDataUnit{
+		functionCode: functionCode | 0x80,
+		data:         []byte{byte(exception)},
+		length:       1,
+	}
+}
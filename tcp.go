@@ -1,7 +1,10 @@
 package modbus
 
 import (
-	"fmt"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -12,6 +15,8 @@ const (
 	defaultTcpReadTimeout    = 1 * time.Second
 	defaultTcpWriteTimeout   = 1 * time.Second
 	defaultTcpKeepAlive      = 30 * time.Second
+	// defaultMaxPipelineTimeouts 流水线模式下断开连接前允许的连续超时次数
+	defaultMaxPipelineTimeouts = 3
 )
 
 type TcpTransporter struct {
@@ -20,68 +25,239 @@ type TcpTransporter struct {
 	KeepAlive      time.Duration
 	ReadTimeout    time.Duration
 	WriteTimeout   time.Duration
-	mu             sync.Mutex
-	conn           net.Conn
+	// TurnaroundDelay 广播后到下一次请求的等待时间,默认100ms
+	TurnaroundDelay time.Duration
+	mu              transportLock
+	conn            net.Conn
+	// reader 连接的读缓冲,跨越多个TCP分段读取完整帧
+	reader *bufio.Reader
+	// idle 广播转换延时结束时间
+	idle time.Time
+	// MaxInFlight 同时等待响应的最大请求数,大于1时TCP帧启用流水线模式,需在首次发送前设置
+	// 流水线模式下由后台协程读取响应并按事务标识分发,ReadTimeout 为每个请求的响应超时
+	MaxInFlight int
+	// MaxPipelineTimeouts 流水线模式下连续超时次数达到该值时断开连接,由重连策略接管,默认3
+	MaxPipelineTimeouts int
+	pipeline            *tcpPipeline
+	windowOnce          sync.Once
+	window              chan struct{}
+	// Reconnect 重连策略,为nil时连接失败后下次发送立即重连
+	Reconnect *ReconnectPolicy
+	// OnConnect 连接建立后调用,OnDisconnect 连接断开后调用,err为断开原因,主动关闭时为nil
+	// 回调在持有传输器锁时同步执行,流水线模式下可能在后台读取协程中执行,不能在回调中使用同一传输器发送请求
+	OnConnect    func()
+	OnDisconnect func(err error)
+	monitor      connectionMonitor
 }
 
 func (mb *TcpTransporter) Send(aduRequest ApplicationDataUnit) (aduResponse []byte, err error) {
-	mb.mu.Lock()
+	return mb.SendContext(context.Background(), aduRequest)
+}
+
+// SendContext 发送请求并等待响应,ctx的截止时间早于读写超时时以ctx为准,ctx取消时立即中止读写并断开连接
+func (mb *TcpTransporter) SendContext(ctx context.Context, aduRequest ApplicationDataUnit) (aduResponse []byte, err error) {
+	if mb.MaxInFlight > 1 {
+		return mb.sendPipelined(ctx, aduRequest)
+	}
+	if err = mb.mu.Lock(ctx); err != nil {
+		return
+	}
 	defer mb.mu.Unlock()
+	if err = waitTurnaround(ctx, mb.idle); err != nil {
+		return
+	}
 
 	if !mb.Connected() {
-		err = mb.connect()
+		err = mb.connect(ctx)
 		if err != nil {
 			return
 		}
 	}
+	conn := mb.conn
+	stop := watchContext(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+	defer func() {
+		if err != nil && contextErr(ctx) != nil {
+			err = contextErr(ctx)
+			_ = mb.disconnect(err)
+		}
+	}()
 	tcpWriteTimeout := defaultTcpWriteTimeout
 	if mb.WriteTimeout > 0 {
 		tcpWriteTimeout = mb.WriteTimeout
 	}
-	err = mb.conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+	err = conn.SetWriteDeadline(contextDeadline(ctx, tcpWriteTimeout))
 	if err != nil {
-		_ = mb.close()
+		_ = mb.disconnect(err)
 		return
 	}
-	_, err = mb.conn.Write(aduRequest.GetData())
+	_, err = conn.Write(aduRequest.GetData())
 	if err != nil {
-		_ = mb.close()
+		_ = mb.disconnect(err)
 		return
 	}
 	tcpReadTimeout := defaultTcpReadTimeout
 	if mb.ReadTimeout > 0 {
 		tcpReadTimeout = mb.ReadTimeout
 	}
-	err = mb.conn.SetReadDeadline(time.Now().Add(tcpReadTimeout))
+	err = conn.SetReadDeadline(contextDeadline(ctx, tcpReadTimeout))
+	if err != nil {
+		_ = mb.disconnect(err)
+		return
+	}
+	aduResponse, err = mb.readFrame(aduRequest)
+	if err != nil {
+		_ = mb.disconnect(err)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			err = &TimeoutError{Err: err}
+		}
+		return
+	}
+	return
+}
+
+// readFrame 按请求的帧格式从连接读取一帧响应
+// TCP按报文头长度读取并丢弃事务标识不一致的过期响应,RTU按功能码及字节数预测帧长度,ASCII读取至换行符
+func (mb *TcpTransporter) readFrame(aduRequest ApplicationDataUnit) (frame []byte, err error) {
+	switch aduRequest.GetMode() {
+	case RTU:
+		return mb.readRtuFrame(aduRequest)
+	case ASCII:
+		return mb.readAsciiFrame()
+	}
+	request := aduRequest.GetData()
+	for {
+		if frame, err = readTcpFrame(mb.reader); err != nil {
+			return
+		}
+		// 丢弃先前超时请求的迟到响应
+		if binary.BigEndian.Uint16(frame) == binary.BigEndian.Uint16(request) {
+			return
+		}
+	}
+}
+
+// readTcpFrame 读取报文头,再按报文头中的长度读取剩余数据
+func readTcpFrame(r io.Reader) (frame []byte, err error) {
+	header := make([]byte, tcpHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if length < 2 || length+6 > tcpMaxSize {
+		err = framingError(TCP, "length in response header '%v' must be between '%v' and '%v'", length, 2, tcpMaxSize-6)
+		return
+	}
+	frame = make([]byte, tcpHeaderSize+length-1)
+	copy(frame, header)
+	_, err = io.ReadFull(r, frame[tcpHeaderSize:])
+	return
+}
+
+func (mb *TcpTransporter) readRtuFrame(aduRequest ApplicationDataUnit) (frame []byte, err error) {
+	frame = make([]byte, 2, rtuMaxSize)
+	if _, err = io.ReadFull(mb.reader, frame); err != nil {
+		return
+	}
+	if !responsePredictable(aduRequest.GetPDU(), frame[1]) {
+		// 未注册的功能码无法预测长度,读取已到达的数据
+		temp := make([]byte, rtuMaxSize)
+		n, e := mb.reader.Read(temp)
+		if e != nil {
+			return nil, e
+		}
+		return append(frame, temp[:n]...), nil
+	}
+	for {
+		length, ok := rtuFrameLength(aduRequest, frame)
+		if ok && length > rtuMaxSize {
+			err = framingError(RTU, "response frame length '%v' exceeds the maximum limit of '%v'", length, rtuMaxSize)
+			return
+		}
+		if ok && len(frame) >= length {
+			return frame[:length], nil
+		}
+		// 长度未知时逐字节读取字节数等字段
+		next := len(frame) + 1
+		if ok {
+			next = length
+		}
+		if next > rtuMaxSize {
+			err = framingError(RTU, "response frame exceeds the maximum limit of '%v'", rtuMaxSize)
+			return
+		}
+		n := len(frame)
+		frame = frame[:next]
+		if _, err = io.ReadFull(mb.reader, frame[n:]); err != nil {
+			return
+		}
+	}
+}
+
+func (mb *TcpTransporter) readAsciiFrame() (frame []byte, err error) {
+	line, err := mb.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > asciiMaxSize+len(asciiStart)+len(asciiEnd) {
+		err = framingError(ASCII, "response frame exceeds the maximum limit of '%v'", asciiMaxSize+len(asciiStart)+len(asciiEnd))
+		return
+	}
 	if err != nil {
-		_ = mb.close()
 		return
 	}
-	temp := make([]byte, tcpMaxSize*2)
-	rl, err := mb.conn.Read(temp)
-	if err != nil && rl == 0 {
-		_ = mb.close()
+	frame = make([]byte, len(line))
+	copy(frame, line)
+	return
+}
+
+// Broadcast 发送广播请求,不等待响应,用于转发至串行链路的网关
+func (mb *TcpTransporter) Broadcast(ctx context.Context, aduRequest ApplicationDataUnit) (err error) {
+	if err = mb.mu.Lock(ctx); err != nil {
+		return
+	}
+	defer mb.mu.Unlock()
+	if err = waitTurnaround(ctx, mb.idle); err != nil {
+		return
+	}
+	if !mb.Connected() {
+		if err = mb.connect(ctx); err != nil {
+			return
+		}
+	}
+	tcpWriteTimeout := defaultTcpWriteTimeout
+	if mb.WriteTimeout > 0 {
+		tcpWriteTimeout = mb.WriteTimeout
+	}
+	if err = mb.conn.SetWriteDeadline(contextDeadline(ctx, tcpWriteTimeout)); err != nil {
+		_ = mb.disconnect(err)
 		return
 	}
-	if rl <= 0 {
-		err = fmt.Errorf("modbus: Read  data is  empty")
+	if _, err = mb.conn.Write(aduRequest.GetData()); err != nil {
+		_ = mb.disconnect(err)
 		return
 	}
-	aduResponse = make([]byte, rl)
-	copy(aduResponse, temp[:rl])
+	mb.idle = time.Now().Add(turnaroundDelay(mb.TurnaroundDelay))
 	return
 }
 func (mb *TcpTransporter) Connected() bool {
 	return mb.conn != nil
 }
+
+// State 返回连接状态,可在其他协程中调用
+func (mb *TcpTransporter) State() ConnectionState {
+	return mb.monitor.State()
+}
 func (mb *TcpTransporter) Open() error {
-	mb.mu.Lock()
+	ctx := context.Background()
+	_ = mb.mu.Lock(ctx)
 	defer mb.mu.Unlock()
-	return mb.connect()
+	return mb.connect(ctx)
 }
 
-func (mb *TcpTransporter) connect() error {
+// connect 建立连接,重连退避期间返回 OfflineError
+func (mb *TcpTransporter) connect(ctx context.Context) error {
 	if mb.conn == nil {
+		if err := mb.monitor.allow(mb.Reconnect); err != nil {
+			return err
+		}
 		tcpConnectTimeout := defaultTcpConnectTimeout
 		tcpKeepAlive := defaultTcpKeepAlive
 		if mb.ConnectTimeout > 0 {
@@ -91,26 +267,39 @@ func (mb *TcpTransporter) connect() error {
 			tcpKeepAlive = mb.KeepAlive
 		}
 		dialer := net.Dialer{Timeout: tcpConnectTimeout, KeepAlive: tcpKeepAlive}
-		conn, err := dialer.Dial("tcp", mb.Address)
+		conn, err := dialer.DialContext(ctx, "tcp", mb.Address)
 		if err != nil {
+			if contextErr(ctx) == nil {
+				mb.monitor.failed(mb.Reconnect, err)
+			}
 			return err
 		}
 		mb.conn = conn
+		mb.reader = bufio.NewReaderSize(conn, tcpMaxSize*2)
+		mb.monitor.connected(mb.OnConnect)
 	}
 	return nil
 }
 
 func (mb *TcpTransporter) Close() error {
-	mb.mu.Lock()
+	_ = mb.mu.Lock(context.Background())
 	defer mb.mu.Unlock()
 
 	return mb.close()
 }
 
 func (mb *TcpTransporter) close() (err error) {
+	return mb.disconnect(nil)
+}
+
+// disconnect 关闭连接并通知 OnDisconnect,cause为断开原因
+func (mb *TcpTransporter) disconnect(cause error) (err error) {
 	if mb.conn != nil {
 		err = mb.conn.Close()
 		mb.conn = nil
+		mb.reader = nil
+		mb.pipeline = nil
+		mb.monitor.disconnected(cause, mb.OnDisconnect)
 	}
 	return
 }
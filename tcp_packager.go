@@ -11,7 +11,8 @@ const (
 	tcpProtocolIdentifier uint16 = 0x0000
 	// Modbus Application Protocol
 	tcpHeaderSize = 7
-	tcpMaxSize    = 259
+	// tcpMaxSize 报文头7字节加最大253字节的协议数据单元
+	tcpMaxSize = tcpHeaderSize + pduMaxSize
 )
 
 // tcpPackager  tcp包解析器
@@ -19,16 +20,21 @@ type tcpPackager struct {
 	transactionID uint16
 	slaveID       byte
 	mux           sync.Mutex
+	// parent 非nil时为 ForUnit 创建的视图,事务标识由parent分配
+	parent *tcpPackager
 }
 
 func (p *tcpPackager) transaction() uint16 {
+	if p.parent != nil {
+		return p.parent.transaction()
+	}
 	p.mux.Lock()
 	defer p.mux.Unlock()
 	p.transactionID++
 	return p.transactionID
 }
 
-func (p *tcpPackager) Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, err error) {
+func (p *tcpPackager) Encode(pdu ProtocolDataUnit) (adu ApplicationDataUnit, err error) {
 	aduLength := tcpHeaderSize + 1 + len(pdu.GetData())
 	if aduLength > tcpMaxSize {
 		err = fmt.Errorf("modbus: length of data '%v' must not be bigger than '%v'", aduLength, tcpMaxSize)
@@ -61,40 +67,24 @@ func (p *tcpPackager) Encode(pdu protocolDataUnit) (adu ApplicationDataUnit, err
 func (p *tcpPackager) Decode(results []byte) (adu ApplicationDataUnit, err error) {
 	allLength := len(results)
 	if allLength > tcpMaxSize {
-		err = fmt.Errorf("modbus: response data size '%v' exceeds the maximum limit of '%v'", allLength, tcpMaxSize)
+		err = framingError(TCP, "response data size '%v' exceeds the maximum limit of '%v'", allLength, tcpMaxSize)
 		return
 	}
-	if allLength < tcpHeaderSize {
-		err = fmt.Errorf("modbus: response data size '%v' less than maximum limit of '%v'", allLength, tcpHeaderSize)
+	if allLength < tcpHeaderSize+1 {
+		err = framingError(TCP, "response data size '%v' less than minimum limit of '%v'", allLength, tcpHeaderSize+1)
 		return
 	}
 	// Read length value in the header
 	length := int(binary.BigEndian.Uint16(results[4:6]))
 	slaveID := results[tcpHeaderSize-1]
 	if length+6 != allLength {
-		err = fmt.Errorf("modbus: length in response '%v' does not match pdu data length '%v'", allLength, length)
+		err = framingError(TCP, "length in response '%v' does not match pdu data length '%v'", allLength, length)
 		return
 	}
 	functionCode := results[tcpHeaderSize]
-	pduLength := length
-	var pdu protocolDataUnit
-	switch functionCode {
-	//read
-	case FuncCodeReadDiscreteInputs, FuncCodeReadCoils, FuncCodeReadInputRegisters, FuncCodeReadHoldingRegisters:
-		pduLength = int(results[8])
-		pduData := results[tcpHeaderSize+2:]
-		pdu = protocolDataUnit{
-			functionCode: functionCode,
-			data:         pduData,
-			length:       pduLength,
-		}
-	default:
-		pduData := results[tcpHeaderSize+1:]
-		pdu = protocolDataUnit{
-			functionCode: functionCode,
-			data:         pduData,
-			length:       len(pduData),
-		}
+	pdu, err := decodePDU(TCP, functionCode, results[tcpHeaderSize+1:])
+	if err != nil {
+		return
 	}
 	adu = applicationDataUnit{
 		slaveID:      slaveID,
@@ -107,34 +97,61 @@ func (p *tcpPackager) Decode(results []byte) (adu ApplicationDataUnit, err error
 	}
 	return
 }
-func (p *tcpPackager) Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
-	if aduRequest.GetSlaveId() != aduResponse.GetSlaveId() {
-		err = fmt.Errorf("modbus: aduRequest  slaveId '%v' and aduResponse slaveId '%v' are inconsistent", aduRequest.GetSlaveId(), aduResponse.GetSlaveId())
+func (p *tcpPackager) DecodeRequest(results []byte) (adu ApplicationDataUnit, request *Request, err error) {
+	allLength := len(results)
+	if allLength > tcpMaxSize {
+		err = framingError(TCP, "request data size '%v' exceeds the maximum limit of '%v'", allLength, tcpMaxSize)
+		return
+	}
+	if allLength < tcpHeaderSize+1 {
+		err = framingError(TCP, "request data size '%v' less than minimum limit of '%v'", allLength, tcpHeaderSize+1)
 		return
 	}
-	if aduRequest.GetFunctionCode() != aduResponse.GetFunctionCode() {
-		if aduResponse.GetFunctionCode() == aduRequest.GetFunctionCode()+0x80 {
-			err = fmt.Errorf("modbus: error   errorCode '%X'", aduResponse.GetFunctionCode())
-			data := aduResponse.GetPDU().GetData()
-			if len(data) == 0 {
-				return
-			}
-			text, exist := faults[data[0]]
-			if !exist {
-				return
-			}
-			err = fmt.Errorf("modbus: error errorCode: '%X' %s", aduResponse.GetFunctionCode(), text)
-			return
-		}
-		err = fmt.Errorf("modbus: aduRequest  functionCode '%v' and aduResponse functionCode '%v' are inconsistent", aduRequest.GetFunctionCode(), aduResponse.GetFunctionCode())
+	protocolID := binary.BigEndian.Uint16(results[2:4])
+	if protocolID != tcpProtocolIdentifier {
+		err = framingError(TCP, "request protocol id '%v' does not match '%v'", protocolID, tcpProtocolIdentifier)
 		return
 	}
+	length := int(binary.BigEndian.Uint16(results[4:6]))
+	if length+6 != allLength {
+		err = framingError(TCP, "length in request '%v' does not match pdu data length '%v'", allLength, length)
+		return
+	}
+	slaveID := results[tcpHeaderSize-1]
+	pduData := results[tcpHeaderSize+1:]
+	pdu := protocolDataUnit{
+		functionCode: results[tcpHeaderSize],
+		data:         pduData,
+		length:       len(pduData),
+	}
+	adu = applicationDataUnit{
+		slaveID:      slaveID,
+		length:       length,
+		pdu:          pdu,
+		checkSumByte: []byte{0},
+		checkSum:     0,
+		data:         results,
+		mode:         TCP,
+	}
+	request, err = ParseRequest(pdu)
+	request.SlaveID = slaveID
+	request.TransactionID = binary.BigEndian.Uint16(results)
+	return
+}
+func (p *tcpPackager) Verify(aduRequest ApplicationDataUnit, aduResponse ApplicationDataUnit) (err error) {
 	requestData := aduRequest.GetData()
 	responseData := aduResponse.GetData()
 	requestTransaction := binary.BigEndian.Uint16(requestData)
 	responseTransaction := binary.BigEndian.Uint16(responseData)
 	if requestTransaction != responseTransaction {
-		err = fmt.Errorf("modbus: response transaction id '%v' does not match request '%v'", responseTransaction, requestTransaction)
+		err = &TransactionError{Request: requestTransaction, Response: responseTransaction}
+		return
+	}
+	if aduRequest.GetSlaveId() != aduResponse.GetSlaveId() {
+		err = fmt.Errorf("modbus: aduRequest  slaveId '%v' and aduResponse slaveId '%v' are inconsistent", aduRequest.GetSlaveId(), aduResponse.GetSlaveId())
+		return
+	}
+	if err = verifyException(aduRequest, aduResponse); err != nil {
 		return
 	}
 	if requestData[6] != responseData[6] {
@@ -144,6 +161,14 @@ func (p *tcpPackager) Verify(aduRequest ApplicationDataUnit, aduResponse Applica
 	return
 }
 
+func (p *tcpPackager) ForUnit(slaveID byte) Packager {
+	parent := p
+	if p.parent != nil {
+		parent = p.parent
+	}
+	return &tcpPackager{slaveID: slaveID, parent: parent}
+}
+
 func NewTcpPackager(slaveID byte) (p Packager) {
 	p = &tcpPackager{slaveID: slaveID}
 	return
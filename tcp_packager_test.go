@@ -120,3 +120,42 @@ func TestTransactionID(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestTcpPackagerForUnit(t *testing.T) {
+	pk := NewTcpPackager(1)
+	view := pk.ForUnit(2).ForUnit(3)
+	for i, p := range []Packager{pk, view, pk, view} {
+		adu, err := p.Encode(protocolDataUnit{functionCode: FuncCodeReadHoldingRegisters, data: dataBlock(0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := adu.GetData()
+		if id := int(data[0])<<8 | int(data[1]); id != i+1 {
+			t.Fatalf("unexpected transaction id %d, want %d", id, i+1)
+		}
+		if want := []byte{1, 3}[i%2]; adu.GetSlaveId() != want || data[6] != want {
+			t.Fatalf("unexpected unit id %d, want %d", data[6], want)
+		}
+	}
+}
+
+// 应用数据单元最大260字节:7字节报文头加253字节协议数据单元
+func TestTcpPackagerMaxSize(t *testing.T) {
+	p := NewTcpPackager(1)
+	if _, err := p.Encode(NewProtocolDataUnit(0x41, make([]byte, pduMaxSize-1))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Encode(NewProtocolDataUnit(0x41, make([]byte, pduMaxSize))); err == nil {
+		t.Fatal("expected error for 261 byte adu")
+	}
+	frame := tcpFrame(1, 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, append([]byte{pduMaxSize - 2}, make([]byte, pduMaxSize-2)...)))
+	if len(frame) != 260 {
+		t.Fatalf("unexpected frame length %v", len(frame))
+	}
+	if _, err := p.Decode(frame); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Decode(append(frame, 0)); err == nil {
+		t.Fatal("expected error for 261 byte frame")
+	}
+}
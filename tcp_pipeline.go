@@ -0,0 +1,209 @@
+package modbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// tcpPipelineResult 后台协程分发的响应
+type tcpPipelineResult struct {
+	frame []byte
+	err   error
+}
+
+// tcpPipeline 一个连接上的流水线状态,后台协程读取响应并按事务标识分发给等待的请求
+type tcpPipeline struct {
+	conn    net.Conn
+	mu      sync.Mutex
+	pending map[uint16]chan tcpPipelineResult
+	// err 后台协程退出原因,不为nil时连接已不可用
+	err  error
+	done chan struct{}
+	// timeouts 连续超时的请求数,收到响应时清零
+	timeouts int
+	// exit 后台协程因连接出错退出时调用
+	exit func(p *tcpPipeline, err error)
+}
+
+func newTcpPipeline(conn net.Conn, reader *bufio.Reader, exit func(p *tcpPipeline, err error)) (p *tcpPipeline) {
+	p = &tcpPipeline{
+		conn:    conn,
+		pending: make(map[uint16]chan tcpPipelineResult),
+		done:    make(chan struct{}),
+		exit:    exit,
+	}
+	// 流水线模式下读超时由每个请求单独计时
+	_ = conn.SetReadDeadline(time.Time{})
+	go p.read(reader)
+	return
+}
+
+func (p *tcpPipeline) read(reader *bufio.Reader) {
+	for {
+		frame, err := readTcpFrame(reader)
+		if err != nil {
+			// 连接已由超时或写入失败关闭时以最初的原因断开
+			p.exit(p, p.fail(err))
+			return
+		}
+		p.mu.Lock()
+		p.timeouts = 0
+		// 无等待者的响应为超时或已取消请求的迟到响应,直接丢弃
+		if ch, exist := p.pending[binary.BigEndian.Uint16(frame)]; exist {
+			delete(p.pending, binary.BigEndian.Uint16(frame))
+			ch <- tcpPipelineResult{frame: frame}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// fail 关闭连接并以err通知所有等待的请求,返回最先记录的失败原因
+func (p *tcpPipeline) fail(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.err = err
+	_ = p.conn.Close()
+	for id, ch := range p.pending {
+		ch <- tcpPipelineResult{err: err}
+		delete(p.pending, id)
+	}
+	close(p.done)
+	return err
+}
+
+func (p *tcpPipeline) closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *tcpPipeline) register(transactionID uint16) (ch chan tcpPipelineResult, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if _, exist := p.pending[transactionID]; exist {
+		return nil, fmt.Errorf("modbus: transaction id '%v' is already in flight", transactionID)
+	}
+	ch = make(chan tcpPipelineResult, 1)
+	p.pending[transactionID] = ch
+	return
+}
+
+func (p *tcpPipeline) cancel(transactionID uint16) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, transactionID)
+}
+
+// timeout 放弃超时的请求,连续超时达到limit时返回true,此时连接可能已半开
+func (p *tcpPipeline) timeout(transactionID uint16, limit int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, transactionID)
+	p.timeouts++
+	return p.timeouts >= limit
+}
+
+// pipelineExited 流水线连接失效时断开连接,使 State 及 OnDisconnect 及时反映断线
+func (mb *TcpTransporter) pipelineExited(p *tcpPipeline, err error) {
+	_ = mb.mu.Lock(context.Background())
+	defer mb.mu.Unlock()
+	if mb.pipeline == p {
+		_ = mb.disconnect(err)
+	}
+}
+
+// sendPipelined 流水线模式发送,写入请求后释放锁,在窗口内与其他请求并发等待响应
+// 请求超时或ctx取消时仅放弃该请求,连续超时达到 MaxPipelineTimeouts 时断开连接
+func (mb *TcpTransporter) sendPipelined(ctx context.Context, aduRequest ApplicationDataUnit) (aduResponse []byte, err error) {
+	if aduRequest.GetMode() != TCP {
+		err = fmt.Errorf("modbus: pipelined transporter requires '%v' frames, got '%v'", TCP, aduRequest.GetMode())
+		return
+	}
+	mb.windowOnce.Do(func() { mb.window = make(chan struct{}, mb.MaxInFlight) })
+	select {
+	case mb.window <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-mb.window }()
+
+	transactionID := binary.BigEndian.Uint16(aduRequest.GetData())
+	pipeline, ch, err := mb.writePipelined(ctx, aduRequest, transactionID)
+	if err != nil {
+		return
+	}
+	tcpReadTimeout := defaultTcpReadTimeout
+	if mb.ReadTimeout > 0 {
+		tcpReadTimeout = mb.ReadTimeout
+	}
+	timer := time.NewTimer(tcpReadTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-ch:
+		return result.frame, result.err
+	case <-timer.C:
+		limit := mb.MaxPipelineTimeouts
+		if limit <= 0 {
+			limit = defaultMaxPipelineTimeouts
+		}
+		if pipeline.timeout(transactionID, limit) {
+			cause := &TimeoutError{Err: fmt.Errorf("'%v' consecutive pipelined requests timed out", limit)}
+			mb.pipelineExited(pipeline, pipeline.fail(cause))
+		}
+		return nil, &TimeoutError{}
+	case <-ctx.Done():
+		pipeline.cancel(transactionID)
+		return nil, ctx.Err()
+	}
+}
+
+func (mb *TcpTransporter) writePipelined(ctx context.Context, aduRequest ApplicationDataUnit, transactionID uint16) (pipeline *tcpPipeline, ch chan tcpPipelineResult, err error) {
+	if err = mb.mu.Lock(ctx); err != nil {
+		return
+	}
+	defer mb.mu.Unlock()
+	if err = waitTurnaround(ctx, mb.idle); err != nil {
+		return
+	}
+	if mb.pipeline != nil && mb.pipeline.closed() {
+		_ = mb.disconnect(mb.pipeline.err)
+	}
+	if !mb.Connected() {
+		if err = mb.connect(ctx); err != nil {
+			return
+		}
+	}
+	if mb.pipeline == nil {
+		mb.pipeline = newTcpPipeline(mb.conn, mb.reader, mb.pipelineExited)
+	}
+	pipeline = mb.pipeline
+	if ch, err = pipeline.register(transactionID); err != nil {
+		return
+	}
+	tcpWriteTimeout := defaultTcpWriteTimeout
+	if mb.WriteTimeout > 0 {
+		tcpWriteTimeout = mb.WriteTimeout
+	}
+	if err = mb.conn.SetWriteDeadline(contextDeadline(ctx, tcpWriteTimeout)); err == nil {
+		_, err = mb.conn.Write(aduRequest.GetData())
+	}
+	if err != nil {
+		_ = pipeline.fail(err)
+		_ = mb.disconnect(err)
+	}
+	return
+}
@@ -0,0 +1,153 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reverseServer 收齐n个请求后倒序响应,寄存器值为请求地址,地址为skip的请求不响应
+func reverseServer(t *testing.T, n int, skip uint16) string {
+	return startScriptServer(t, func(conn net.Conn) {
+		for {
+			var frames [][]byte
+			for len(frames) < n {
+				frame, err := readTcpFrame(conn)
+				if err != nil {
+					return
+				}
+				frames = append(frames, frame)
+			}
+			for i := len(frames) - 1; i >= 0; i-- {
+				frame := frames[i]
+				address := binary.BigEndian.Uint16(frame[8:])
+				if address == skip {
+					continue
+				}
+				pdu := NewProtocolDataUnit(FuncCodeReadHoldingRegisters, append([]byte{2}, dataBlock(address)...))
+				_, _ = conn.Write(tcpFrame(binary.BigEndian.Uint16(frame), frame[6], pdu))
+			}
+		}
+	})
+}
+
+func TestTcpPipeline(t *testing.T) {
+	st := NewTcpTransporter(reverseServer(t, 4, 0xFFFF))
+	st.MaxInFlight = 4
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	for round := 0; round < 2; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(address uint16) {
+				defer wg.Done()
+				value, err := c.ReadHoldingRegisterValues(address, 1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if value[0] != address {
+					t.Errorf("request %d got response %d", address, value[0])
+				}
+			}(uint16(round*4 + i))
+		}
+		wg.Wait()
+	}
+}
+
+func TestTcpPipelineTimeout(t *testing.T) {
+	st := NewTcpTransporter(reverseServer(t, 2, 1))
+	st.MaxInFlight = 2
+	st.ReadTimeout = 100 * time.Millisecond
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.ReadHoldingRegisterValues(uint16(i), 1)
+		}(i)
+	}
+	wg.Wait()
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	if _, ok := errs[1].(*TimeoutError); !ok {
+		t.Fatalf("unexpected error %v", errs[1])
+	}
+	// 超时不断开连接
+	if !st.Connected() {
+		t.Fatal("connection closed after request timeout")
+	}
+}
+
+func TestTcpPipelineRequiresTcpFrames(t *testing.T) {
+	st := NewTcpTransporter("127.0.0.1:0")
+	st.MaxInFlight = 2
+	if _, _, err := NewClient(NewRtuPackager(1), st).ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("expected error for rtu frames")
+	}
+}
+
+func TestTcpPipelineServerClose(t *testing.T) {
+	address := startScriptServer(t, func(conn net.Conn) {
+		request := make([]byte, 12)
+		_, _ = io.ReadFull(conn, request)
+		_, _ = conn.Write(tcpFrame(binary.BigEndian.Uint16(request), 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 1})))
+	})
+	st := NewTcpTransporter(address)
+	st.MaxInFlight = 2
+	causes := make(chan error, 1)
+	st.OnDisconnect = func(err error) { causes <- err }
+	c := NewModbusClient(NewTcpPackager(1), st)
+	if _, err := c.ReadHoldingRegisterValues(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端关闭连接后无需再次发送即可感知断线
+	select {
+	case err := <-causes:
+		if err == nil {
+			t.Fatal("expected disconnect cause")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+	if st.State() != StateDisconnected || st.Connected() {
+		t.Fatalf("unexpected state %v", st.State())
+	}
+}
+
+func TestTcpPipelineConsecutiveTimeouts(t *testing.T) {
+	st := NewTcpTransporter(startSilentServer(t))
+	st.MaxInFlight = 2
+	st.MaxPipelineTimeouts = 2
+	st.ReadTimeout = 30 * time.Millisecond
+	defer func() { _ = st.Close() }()
+	causes := make(chan error, 1)
+	st.OnDisconnect = func(err error) { causes <- err }
+	c := NewModbusClient(NewTcpPackager(1), st)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ReadHoldingRegisterValues(0, 1); err == nil {
+			t.Fatal("expected timeout")
+		}
+	}
+	select {
+	case err := <-causes:
+		var timeout *TimeoutError
+		if !errors.As(err, &timeout) {
+			t.Fatalf("unexpected disconnect cause %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+	if st.State() != StateDisconnected {
+		t.Fatalf("unexpected state %v", st.State())
+	}
+}
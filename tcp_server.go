@@ -0,0 +1,172 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed 服务端已关闭
+var ErrServerClosed = errors.New("modbus: server closed")
+
+// Server modbus tcp服务端(从站)
+type Server struct {
+	Handler Handler
+	// IdleTimeout 连接空闲超时,为0时不超时
+	IdleTimeout  time.Duration
+	WriteTimeout time.Duration
+	mu           sync.Mutex
+	listeners    map[net.Listener]struct{}
+	conns        map[net.Conn]struct{}
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+// ListenAndServe 监听tcp地址并处理请求
+func (s *Server) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在监听器上接收连接并处理请求,直到监听器出错或服务端关闭
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l, nil) {
+		_ = l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l, nil)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close 关闭所有监听器及连接,并等待连接处理结束
+func (s *Server) Close() (err error) {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(nil, conn)
+	defer func() { _ = conn.Close() }()
+	header := make([]byte, tcpHeaderSize)
+	packager := &tcpPackager{}
+	for {
+		if s.IdleTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if length < 2 || length+6 > tcpMaxSize {
+			return
+		}
+		frame := make([]byte, tcpHeaderSize+length-1)
+		copy(frame, header)
+		if _, err := io.ReadFull(conn, frame[tcpHeaderSize:]); err != nil {
+			return
+		}
+		_, request, err := packager.DecodeRequest(frame)
+		if request == nil {
+			return
+		}
+		response := serve(s.Handler, request.SlaveID, request, err)
+		tcpWriteTimeout := defaultTcpWriteTimeout
+		if s.WriteTimeout > 0 {
+			tcpWriteTimeout = s.WriteTimeout
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+		if _, err := conn.Write(tcpFrame(request.TransactionID, request.SlaveID, response)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) track(l net.Listener, conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if l != nil {
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	}
+	if conn != nil {
+		if s.conns == nil {
+			s.conns = make(map[net.Conn]struct{})
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+	}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener, conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l != nil {
+		delete(s.listeners, l)
+	}
+	if conn != nil {
+		delete(s.conns, conn)
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// tcpFrame 使用指定事务标识组装MBAP帧
+func tcpFrame(transactionID uint16, slaveID byte, pdu ProtocolDataUnit) []byte {
+	bs := bytes.NewBuffer([]byte{})
+	_ = binary.Write(bs, binary.BigEndian, transactionID)
+	_ = binary.Write(bs, binary.BigEndian, tcpProtocolIdentifier)
+	_ = binary.Write(bs, binary.BigEndian, uint16(1+1+len(pdu.GetData())))
+	bs.WriteByte(slaveID)
+	bs.WriteByte(pdu.GetFunctionCode())
+	bs.Write(pdu.GetData())
+	return bs.Bytes()
+}
+
+func NewServer(handler Handler) (s *Server) {
+	s = &Server{
+		Handler: handler,
+	}
+	return
+}
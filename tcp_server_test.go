@@ -0,0 +1,133 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+type testHandler struct {
+	coils     []bool
+	registers []uint16
+}
+
+func (h *testHandler) ReadCoils(slaveID byte, address, quantity uint16) ([]bool, error) {
+	if int(address)+int(quantity) > len(h.coils) {
+		return nil, ExceptionIllegalDataAddress
+	}
+	return h.coils[address : address+quantity], nil
+}
+func (h *testHandler) ReadDiscreteInputs(slaveID byte, address, quantity uint16) ([]bool, error) {
+	return h.ReadCoils(slaveID, address, quantity)
+}
+func (h *testHandler) WriteSingleCoil(slaveID byte, address uint16, value bool) error {
+	return h.WriteMultipleCoils(slaveID, address, []bool{value})
+}
+func (h *testHandler) WriteMultipleCoils(slaveID byte, address uint16, value []bool) error {
+	if int(address)+len(value) > len(h.coils) {
+		return ExceptionIllegalDataAddress
+	}
+	copy(h.coils[address:], value)
+	return nil
+}
+func (h *testHandler) ReadInputRegisters(slaveID byte, address, quantity uint16) ([]uint16, error) {
+	return h.ReadHoldingRegisters(slaveID, address, quantity)
+}
+func (h *testHandler) ReadHoldingRegisters(slaveID byte, address, quantity uint16) ([]uint16, error) {
+	if int(address)+int(quantity) > len(h.registers) {
+		return nil, ExceptionIllegalDataAddress
+	}
+	return h.registers[address : address+quantity], nil
+}
+func (h *testHandler) WriteSingleRegister(slaveID byte, address, value uint16) error {
+	return h.WriteMultipleRegisters(slaveID, address, []uint16{value})
+}
+func (h *testHandler) WriteMultipleRegisters(slaveID byte, address uint16, value []uint16) error {
+	if int(address)+len(value) > len(h.registers) {
+		return ExceptionIllegalDataAddress
+	}
+	copy(h.registers[address:], value)
+	return nil
+}
+func (h *testHandler) ReadWriteMultipleRegisters(slaveID byte, readAddress, readQuantity, writeAddress uint16, value []uint16) ([]uint16, error) {
+	if err := h.WriteMultipleRegisters(slaveID, writeAddress, value); err != nil {
+		return nil, err
+	}
+	return h.ReadHoldingRegisters(slaveID, readAddress, readQuantity)
+}
+
+func startTestServer(t *testing.T, handler Handler) (s *Server, address string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = NewServer(handler)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Close() })
+	return s, l.Addr().String()
+}
+
+func TestServerReadWriteRegisters(t *testing.T) {
+	_, address := startTestServer(t, &testHandler{coils: make([]bool, 16), registers: make([]uint16, 16)})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewClient(NewTcpPackager(1), st)
+	if _, _, err := c.WriteMultipleRegisters(2, 2, dataBlock(0x1234, 0x5678)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.WriteSingleRegister(4, 0x9abc); err != nil {
+		t.Fatal(err)
+	}
+	_, results, err := c.ReadHoldingRegisters(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := registers(results.GetPDU().GetData())
+	if len(value) != 3 || value[0] != 0x1234 || value[1] != 0x5678 || value[2] != 0x9abc {
+		t.Fatalf("unexpected registers %v", value)
+	}
+	if _, _, err = c.WriteMultipleCoils(1, 3, []bool{true, false, true}); err != nil {
+		t.Fatal(err)
+	}
+	_, results, err = c.ReadCoils(0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coils := fromBit(results.GetPDU().GetData(), 4)
+	if coils[0] || !coils[1] || coils[2] || !coils[3] {
+		t.Fatalf("unexpected coils %v", coils)
+	}
+}
+
+func TestServerException(t *testing.T) {
+	_, address := startTestServer(t, &testHandler{registers: make([]uint16, 4)})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewClient(NewTcpPackager(1), st)
+	_, results, err := c.ReadHoldingRegisters(2, 3)
+	if err == nil {
+		t.Fatal("expected exception response")
+	}
+	if results.GetFunctionCode() != FuncCodeReadHoldingRegisters|0x80 {
+		t.Fatalf("unexpected function code '%X'", results.GetFunctionCode())
+	}
+	if data := results.GetData(); data[len(data)-1] != byte(ExceptionIllegalDataAddress) {
+		t.Fatalf("unexpected exception code '%X'", data[len(data)-1])
+	}
+}
+
+func TestServerIllegalFunction(t *testing.T) {
+	response := servePDU(&testHandler{}, 1, protocolDataUnit{functionCode: 0x41, data: []byte{0, 0}})
+	if response.GetFunctionCode() != 0xC1 || response.GetData()[0] != byte(ExceptionIllegalFunction) {
+		t.Fatalf("unexpected response '%X' %v", response.GetFunctionCode(), response.GetData())
+	}
+	frame := tcpFrame(7, 1, response)
+	if binary.BigEndian.Uint16(frame) != 7 || binary.BigEndian.Uint16(frame[4:]) != 3 {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+}
+
+func servePDU(handler Handler, slaveID byte, pdu ProtocolDataUnit) protocolDataUnit {
+	request, err := ParseRequest(pdu)
+	return serve(handler, slaveID, request, err)
+}
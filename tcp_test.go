@@ -0,0 +1,110 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startScriptServer 启动按脚本处理连接的测试服务端
+func startScriptServer(t *testing.T, script func(conn net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		script(conn)
+	}()
+	return l.Addr().String()
+}
+
+func TestTcpSplitAndCoalescedFrames(t *testing.T) {
+	address := startScriptServer(t, func(conn net.Conn) {
+		request := make([]byte, 12)
+		// 响应分两个分段发送
+		_, _ = io.ReadFull(conn, request)
+		frame := tcpFrame(1, 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2}))
+		_, _ = conn.Write(frame[:5])
+		time.Sleep(20 * time.Millisecond)
+		_, _ = conn.Write(frame[5:])
+		// 过期响应与两个请求的响应合并发送
+		_, _ = io.ReadFull(conn, request)
+		var data []byte
+		data = append(data, tcpFrame(1, 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 9}))...)
+		data = append(data, tcpFrame(2, 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 3}))...)
+		data = append(data, tcpFrame(3, 1, NewProtocolDataUnit(FuncCodeReadHoldingRegisters, []byte{2, 0, 4}))...)
+		_, _ = conn.Write(data)
+		_, _ = io.ReadFull(conn, request)
+	})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	for _, want := range [][]uint16{{1, 2}, {3}, {4}} {
+		value, err := c.ReadHoldingRegisterValues(0, uint16(len(want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if value[i] != want[i] {
+				t.Fatalf("unexpected registers %v, want %v", value, want)
+			}
+		}
+	}
+}
+
+func TestRtuOverTcpFraming(t *testing.T) {
+	address := startScriptServer(t, func(conn net.Conn) {
+		request := make([]byte, 8)
+		_, _ = io.ReadFull(conn, request)
+		frame := rtuFrame(1, FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2})
+		_, _ = conn.Write(frame[:2])
+		time.Sleep(20 * time.Millisecond)
+		_, _ = conn.Write(frame[2:4])
+		time.Sleep(20 * time.Millisecond)
+		// 异常响应与下一帧合并发送
+		_, _ = conn.Write(append(frame[4:], rtuFrame(1, FuncCodeReadHoldingRegisters|0x80, []byte{2})...))
+		_, _ = io.ReadFull(conn, request)
+	})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewRtuPackager(1), st)
+	value, err := c.ReadHoldingRegisterValues(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value[0] != 1 || value[1] != 2 {
+		t.Fatalf("unexpected registers %v", value)
+	}
+	if _, err = c.ReadHoldingRegisterValues(0, 2); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAsciiOverTcpFraming(t *testing.T) {
+	address := startScriptServer(t, func(conn net.Conn) {
+		request := make([]byte, 17)
+		_, _ = io.ReadFull(conn, request)
+		frame := asciiFrame(1, FuncCodeReadHoldingRegisters, []byte{2, 0, 7})
+		_, _ = conn.Write(frame[:6])
+		time.Sleep(20 * time.Millisecond)
+		_, _ = conn.Write(frame[6:])
+	})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewAsciiPackager(1), st)
+	value, err := c.ReadHoldingRegisterValues(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value[0] != 7 {
+		t.Fatalf("unexpected registers %v", value)
+	}
+}
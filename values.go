@@ -0,0 +1,51 @@
+package modbus
+
+import (
+	"encoding/binary"
+)
+
+// RegisterValues 将读寄存器响应解析为寄存器值,并校验字节数与请求数量一致
+// 功能码:3、4、23
+func RegisterValues(results ApplicationDataUnit, quantity uint16) (value []uint16, err error) {
+	pdu := results.GetPDU()
+	data := pdu.GetData()
+	count := int(quantity) * 2
+	if pdu.Length() != count || len(data) != count {
+		err = framingError(results.GetMode(), "response byte count '%v' does not match quantity '%v'", pdu.Length(), quantity)
+		return
+	}
+	value = registers(data)
+	return
+}
+
+// BitValues 将读线圈/离散量输入响应解析为位值,按请求数量截取,并校验字节数与请求数量一致
+// 功能码:1、2
+func BitValues(results ApplicationDataUnit, quantity uint16) (value []bool, err error) {
+	pdu := results.GetPDU()
+	data := pdu.GetData()
+	count := (int(quantity) + 7) / 8
+	if pdu.Length() != count || len(data) != count {
+		err = framingError(results.GetMode(), "response byte count '%v' does not match quantity '%v'", pdu.Length(), quantity)
+		return
+	}
+	value = fromBit(data, int(quantity))
+	return
+}
+
+// FIFOQueueValues 将读FIFO队列响应解析为队列中的寄存器值,并校验字节数与FIFO计数一致
+// 功能码:24
+func FIFOQueueValues(results ApplicationDataUnit) (value []uint16, err error) {
+	pdu := results.GetPDU()
+	data := pdu.GetData()
+	if pdu.Length() != len(data) || len(data) < 2 {
+		err = framingError(results.GetMode(), "response byte count '%v' does not match data length '%v'", pdu.Length(), len(data))
+		return
+	}
+	count := int(binary.BigEndian.Uint16(data))
+	if count > 31 || len(data) != 2+count*2 {
+		err = framingError(results.GetMode(), "response fifo count '%v' does not match byte count '%v'", count, len(data))
+		return
+	}
+	value = registers(data[2:])
+	return
+}
@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadValues(t *testing.T) {
+	store := NewMemoryDataStore(16, 16, 16, 16)
+	_ = store.WriteCoils(0, []bool{true, false, true, true, false, false, false, false, false, true})
+	_ = store.WriteInputRegisters(1, []uint16{7, 8})
+	_, address := startTestServer(t, NewStoreHandler(store))
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	coils, err := c.ReadCoilValues(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coils) != 10 || !coils[0] || coils[1] || !coils[3] || !coils[9] {
+		t.Fatalf("unexpected coils %v", coils)
+	}
+	value, err := c.ReadInputRegisterValues(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(value) != 3 || value[0] != 0 || value[1] != 7 || value[2] != 8 {
+		t.Fatalf("unexpected registers %v", value)
+	}
+	_, results, err := c.ReadWriteMultipleRegisters(0, 2, 1, 1, dataBlock(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err = RegisterValues(results, 2); err != nil || value[0] != 0 || value[1] != 9 {
+		t.Fatalf("unexpected registers %v %v", value, err)
+	}
+}
+
+func TestRegisterValuesByteCount(t *testing.T) {
+	results, err := NewRtuPackager(1).Decode(rtuFrame(1, FuncCodeReadHoldingRegisters, []byte{4, 0, 1, 0, 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f *FramingError
+	if _, err = RegisterValues(results, 3); !errors.As(err, &f) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err = BitValues(results, 3); !errors.As(err, &f) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type fifoHandler struct {
+	testHandler
+	queue []uint16
+}
+
+func (h *fifoHandler) ReadFIFOQueue(slaveID byte, address uint16) ([]uint16, error) {
+	if address != 0x04DE {
+		return nil, ExceptionIllegalDataAddress
+	}
+	return h.queue, nil
+}
+
+func TestReadFIFOQueue(t *testing.T) {
+	_, address := startTestServer(t, &fifoHandler{queue: []uint16{0x01B8, 0x1284}})
+	st := NewTcpTransporter(address)
+	defer func() { _ = st.Close() }()
+	c := NewModbusClient(NewTcpPackager(1), st)
+	value, err := c.ReadFIFOQueue(0x04DE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(value) != 2 || value[0] != 0x01B8 || value[1] != 0x1284 {
+		t.Fatalf("unexpected queue %v", value)
+	}
+	if _, err = c.ReadFIFOQueue(0); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFIFOQueueValues(t *testing.T) {
+	data := []byte{0, 6, 0, 2, 0x01, 0xB8, 0x12, 0x84}
+	for _, decode := range []func() (ApplicationDataUnit, error){
+		func() (ApplicationDataUnit, error) {
+			return NewRtuPackager(1).Decode(rtuFrame(1, FuncCodeReadFIFOQueue, data))
+		},
+		func() (ApplicationDataUnit, error) {
+			return NewAsciiPackager(1).Decode(asciiFrame(1, FuncCodeReadFIFOQueue, data))
+		},
+		func() (ApplicationDataUnit, error) {
+			return NewTcpPackager(1).Decode(tcpFrame(1, 1, protocolDataUnit{functionCode: FuncCodeReadFIFOQueue, data: data}))
+		},
+	} {
+		results, err := decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := FIFOQueueValues(results)
+		if err != nil {
+			t.Fatalf("%s: %v", results.GetMode(), err)
+		}
+		if len(value) != 2 || value[0] != 0x01B8 || value[1] != 0x1284 {
+			t.Fatalf("%s: unexpected queue %v", results.GetMode(), value)
+		}
+	}
+	results, _ := NewRtuPackager(1).Decode(rtuFrame(1, FuncCodeReadFIFOQueue, []byte{0, 6, 0, 3, 0x01, 0xB8, 0x12, 0x84}))
+	var e *FramingError
+	if _, err := FIFOQueueValues(results); !errors.As(err, &e) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// 读/写多个寄存器以功能码23发送,响应按字节数解析
+func TestReadWriteMultipleRegistersFunctionCode(t *testing.T) {
+	st := &scriptTransporter{respond: []func(ApplicationDataUnit) []byte{
+		respondPDU(NewProtocolDataUnit(FuncCodeReadWriteMultipleRegisters, []byte{4, 0, 1, 0, 2})),
+	}}
+	c := NewModbusClient(NewTcpPackager(1), st)
+	request, results, err := c.ReadWriteMultipleRegisters(0, 2, 1, 1, dataBlock(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.GetFunctionCode() != FuncCodeReadWriteMultipleRegisters || request.GetData()[tcpHeaderSize] != FuncCodeReadWriteMultipleRegisters {
+		t.Fatalf("unexpected request % x", request.GetData())
+	}
+	if value, err := RegisterValues(results, 2); err != nil || value[0] != 1 || value[1] != 2 {
+		t.Fatalf("unexpected registers %v %v", value, err)
+	}
+	packagers := []struct {
+		packager Packager
+		frame    []byte
+	}{
+		{NewRtuPackager(1), rtuFrame(1, FuncCodeReadWriteMultipleRegisters, []byte{4, 0, 1, 0, 2})},
+		{NewAsciiPackager(1), asciiFrame(1, FuncCodeReadWriteMultipleRegisters, []byte{4, 0, 1, 0, 2})},
+	}
+	for _, p := range packagers {
+		results, err = p.packager.Decode(p.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, err := RegisterValues(results, 2); err != nil || value[1] != 2 {
+			t.Fatalf("%T: unexpected registers %v %v", p.packager, value, err)
+		}
+	}
+}